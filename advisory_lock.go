@@ -0,0 +1,149 @@
+package borm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// AdvisoryKey identifies a PostgreSQL advisory lock. Created with LockKey, LockKeyString or LockKeyPair.
+type AdvisoryKey struct {
+	pair   bool
+	key    int64
+	first  int32
+	second int32
+}
+
+// AdvisoryLock is a session level advisory lock. It pins a dedicated connection until Unlock is called.
+type AdvisoryLock struct {
+	key  AdvisoryKey
+	conn *sql.Conn
+}
+
+func LockKey(key int64) AdvisoryKey {
+	return AdvisoryKey{key: key}
+}
+
+// LockKeyString hashes key with 64 bit FNV-1a, the same string always results in the same lock.
+func LockKeyString(key string) AdvisoryKey {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return AdvisoryKey{key: int64(hash.Sum64())}
+}
+
+// LockKeyPair uses PostgreSQL two keys lock space, which doesn't overlap with single key locks.
+func LockKeyPair(first, second int32) AdvisoryKey {
+	return AdvisoryKey{pair: true, first: first, second: second}
+}
+
+// statement returns the call of the advisory lock function with the key as arguments
+func (k AdvisoryKey) statement(function string) (string, []any) {
+	if k.pair {
+		return fmt.Sprintf("SELECT %s($1, $2)", function), []any{k.first, k.second}
+	}
+	return fmt.Sprintf("SELECT %s($1)", function), []any{k.key}
+}
+
+func (k AdvisoryKey) String() string {
+	if k.pair {
+		return fmt.Sprintf("(%d, %d)", k.first, k.second)
+	}
+	return fmt.Sprintf("%d", k.key)
+}
+
+// AdvisoryLock waits until the session level lock of key is acquired or ctx is done.
+func (m *Commiter) AdvisoryLock(ctx context.Context, key AdvisoryKey) (*AdvisoryLock, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Join(ErrBadConnection, err)
+	}
+
+	statement, args := key.statement("pg_advisory_lock")
+	if _, err := conn.ExecContext(ctx, statement, args...); err != nil {
+		// The lock may have been acquired before the failure
+		discardConn(conn)
+		return nil, ErrorDescription(ErrFailedOperation, fmt.Sprintf("Unable to lock %s", key), err.Error())
+	}
+	return &AdvisoryLock{key: key, conn: conn}, nil
+}
+
+// TryAdvisoryLock acquires the session level lock of key without waiting. Returns false if it is held by another session.
+func (m *Commiter) TryAdvisoryLock(ctx context.Context, key AdvisoryKey) (*AdvisoryLock, bool, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, false, errors.Join(ErrBadConnection, err)
+	}
+
+	var locked bool
+	statement, args := key.statement("pg_try_advisory_lock")
+	if err := conn.QueryRowContext(ctx, statement, args...).Scan(&locked); err != nil {
+		discardConn(conn)
+		return nil, false, ErrorDescription(ErrFailedOperation, fmt.Sprintf("Unable to lock %s", key), err.Error())
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+	return &AdvisoryLock{key: key, conn: conn}, true, nil
+}
+
+// Unlock releases the lock and returns its connection to the pool.
+// If the lock can't be released the connection is closed instead, ending the session and every lock it holds.
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	if l.conn == nil {
+		return ErrorDescription(ErrFailedOperation, fmt.Sprintf("Lock %s already released", l.key))
+	}
+	conn := l.conn
+	l.conn = nil
+
+	var unlocked bool
+	statement, args := l.key.statement("pg_advisory_unlock")
+	if err := conn.QueryRowContext(ctx, statement, args...).Scan(&unlocked); err != nil {
+		discardConn(conn)
+		return ErrorDescription(ErrFailedOperation, fmt.Sprintf("Unable to unlock %s", l.key), err.Error())
+	}
+	if !unlocked {
+		discardConn(conn)
+		return ErrorDescription(ErrFailedOperation, fmt.Sprintf("Lock %s was not held by the session", l.key))
+	}
+	return conn.Close()
+}
+
+// discardConn closes the physical connection of conn instead of returning it to the pool, so the server releases its session locks.
+// database/sql closes connections that report driver.ErrBadConn.
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+}
+
+// AdvisoryXactLock waits until the transaction level lock of key is acquired. It is released when the transaction ends.
+func (t *Transaction) AdvisoryXactLock(key AdvisoryKey) error {
+	statement, args := key.statement("pg_advisory_xact_lock")
+	query := newUnsafeQuery(SELECT, statement)
+	query.CurrentValues = args
+	return t.Do(query)
+}
+
+// TryAdvisoryXactLock acquires the transaction level lock of key without waiting. Returns false if it is held by another session.
+func (t *Transaction) TryAdvisoryXactLock(key AdvisoryKey) (bool, error) {
+	var locked bool
+	statement, args := key.statement("pg_try_advisory_xact_lock")
+	query := newUnsafeQuery(SELECT, statement)
+	query.CurrentValues = args
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		if !rows.Next() {
+			return false, rows.Err()
+		}
+		return true, rows.Scan(&locked)
+	})
+
+	if err := t.Do(query); err != nil {
+		return false, err
+	}
+	return locked, nil
+}
@@ -1,9 +1,10 @@
 package borm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
+	"net/url"
 
 	_ "github.com/lib/pq"
 
@@ -13,21 +14,49 @@ import (
 const (
 	DEBUGGING  = configuration.DEBUGGING
 	PRODUCTION = configuration.PRODUCTION
+
+	CLIENT_CLOCK     = configuration.CLIENT_CLOCK
+	DATABASE_NOW     = configuration.DATABASE_NOW
+	DATABASE_TRIGGER = configuration.DATABASE_TRIGGER
 )
 
 func Settings() *configuration.Configuration {
 	return configuration.Settings()
 }
 func Connect(registor *DatabaseRegistry) (*Commiter, error) {
+	return ConnectContext(context.Background(), registor)
+}
+
+// ConnectContext opens the database of the registry and checks the connection. Gives up when ctx is done.
+func ConnectContext(ctx context.Context, registor *DatabaseRegistry) (*Commiter, error) {
 	if registor.Host == "" || registor.Name == "" || registor.Owner.password == "" || registor.Owner.Name == "" {
 		return nil, errors.New("cannot connect to database. trying to connect with broken (or empty) struct. ")
 	}
-	db, err := sql.Open("postgres", fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", registor.Owner.Name, registor.Owner.password, registor.Host, registor.Name))
+	if registor.Owner.registerErrors != nil {
+		return nil, registor.Owner.registerErrors
+	}
+	db, err := sql.Open("postgres", connectionString(registor))
 	if err != nil {
+		logConnection(ctx, registor.Host, registor.Name, err)
 		return nil, err
 	}
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
+		logConnection(ctx, registor.Host, registor.Name, err)
+		db.Close()
 		return nil, err
 	}
+	logConnection(ctx, registor.Host, registor.Name, nil)
 	return newCommiter(registor, registor.Host, db), nil
 }
+
+// connectionString escapes the registry values into a postgres url
+func connectionString(registor *DatabaseRegistry) string {
+	connection := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(string(registor.Owner.Name), registor.Owner.password),
+		Host:     registor.Host,
+		Path:     "/" + string(registor.Name),
+		RawQuery: "sslmode=disable",
+	}
+	return connection.String()
+}
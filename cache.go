@@ -41,7 +41,6 @@ func (r *TypesCache) RegisterEnum(name string, values ...any) *Enum {
 		// Values are not from the same type
 		typ := reflect.TypeOf(value)
 		if typ.Kind() != targetType.Kind() {
-			fmt.Println(targetType.Kind(), typ.Kind())
 			return &Enum{
 				registerErrors: ErrInvalidType,
 				Typ:            &Typ{Type: ENUM},
@@ -60,6 +59,12 @@ func (r *TypesCache) RegisterEnum(name string, values ...any) *Enum {
 	}
 	typName := TypName(strings.ToLower(name))
 	enum := &Enum{Typ: &Typ{Name: typName, Type: ENUM}, options: options, kind: targetType.Kind()}
+	enum.registerErrors = validateIdentifier("type", string(typName))
+	for _, option := range options {
+		if err := validateIdentifier("enum value", fmt.Sprint(option)); err != nil {
+			enum.registerErrors = err
+		}
+	}
 	(*r)[typName] = enum
 	return enum
 }
@@ -0,0 +1,347 @@
+// Command borm runs migrations and manages databases and users of a borm project.
+//
+//	borm [flags] migrate up|down [n]|to <version>|redo|status|plan|relations
+//	borm [flags] db create|drop <name>
+//	borm [flags] user create|drop <name>
+//	borm [flags] schema dump
+//	borm [flags] introspect
+//
+// Connection flags default to the BORM_HOST, BORM_USER, BORM_PASSWORD and BORM_DATABASE environment variables.
+// Versioned migrations are loaded from the .sql files of --dir and from the Register function of a --plugin.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"plugin"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Noeeekr/borm"
+)
+
+type options struct {
+	host     string
+	user     string
+	password string
+	database string
+
+	dir      string
+	plugin   string
+	owner    string
+	secret   string
+	dryRun   bool
+	json     bool
+	recreate bool
+	ignore   bool
+	debug    bool
+
+	packageName string
+	out         string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+func run(ctx context.Context, args []string) error {
+	var opts options
+	flags := flag.NewFlagSet("borm", flag.ContinueOnError)
+	flags.StringVar(&opts.host, "host", env("BORM_HOST", "localhost:5432"), "postgres host and port")
+	flags.StringVar(&opts.user, "user", env("BORM_USER", "postgres"), "user to connect with")
+	flags.StringVar(&opts.password, "password", env("BORM_PASSWORD", ""), "password of the user")
+	flags.StringVar(&opts.database, "database", env("BORM_DATABASE", "postgres"), "database to connect to")
+	flags.StringVar(&opts.dir, "dir", env("BORM_MIGRATIONS", ""), "directory with <version>_<name>.up.sql and .down.sql migrations")
+	flags.StringVar(&opts.plugin, "plugin", env("BORM_PLUGIN", ""), "go plugin exporting Register(*borm.Commiter) error")
+	flags.StringVar(&opts.owner, "owner", "", "owner of the created database, defaults to --user")
+	flags.StringVar(&opts.secret, "secret", "", "password of the created user")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "print the statements of create and drop commands without running them")
+	flags.BoolVar(&opts.json, "json", false, "print plans as json")
+	flags.BoolVar(&opts.recreate, "recreate", false, "drop and recreate existing objects")
+	flags.BoolVar(&opts.ignore, "ignore", false, "skip existing objects")
+	flags.BoolVar(&opts.debug, "debug", false, "log queries and migrations")
+	flags.StringVar(&opts.packageName, "package", "models", "package of the code generated by introspect")
+	flags.StringVar(&opts.out, "out", "", "file written by introspect, defaults to stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: borm [flags] migrate up|down [n]|to <version>|redo|status|plan|relations")
+		fmt.Fprintln(flags.Output(), "       borm [flags] db create|drop <name>")
+		fmt.Fprintln(flags.Output(), "       borm [flags] user create|drop <name>")
+		fmt.Fprintln(flags.Output(), "       borm [flags] schema dump")
+		fmt.Fprintln(flags.Output(), "       borm [flags] introspect")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 && flags.Arg(0) != "introspect" {
+		flags.Usage()
+		return errors.New("missing command")
+	}
+
+	settings := borm.Settings()
+	settings.Migrations().Enable()
+	if opts.recreate {
+		settings.Migrations().RecreateExisting()
+	}
+	if opts.ignore {
+		settings.Migrations().IgnoreExisting()
+	}
+	if opts.debug {
+		settings.Environment().SetEnvironment(borm.DEBUGGING)
+		settings.Logging().SetLogger(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	}
+
+	commiter, err := connect(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer commiter.DB().Close()
+
+	command, subcommand, rest := flags.Arg(0), flags.Arg(1), flags.Args()[min(2, flags.NArg()):]
+	switch command {
+	case "migrate":
+		return migrate(ctx, commiter, opts, subcommand, rest)
+	case "db":
+		return database(ctx, commiter, opts, subcommand, rest)
+	case "user":
+		return user(ctx, commiter, opts, subcommand, rest)
+	case "schema":
+		if subcommand != "dump" {
+			return fmt.Errorf("unknown schema command %q", subcommand)
+		}
+		if err := load(commiter, opts); err != nil {
+			return err
+		}
+		schema, err := commiter.SchemaSQL()
+		if err != nil {
+			return err
+		}
+		fmt.Print(schema)
+		return nil
+	case "introspect":
+		return introspect(ctx, commiter, opts)
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
+func connect(ctx context.Context, opts options) (*borm.Commiter, error) {
+	registry := borm.RegisterDatabase(opts.database, opts.host, borm.RegisterUser(opts.user, opts.password))
+	return borm.ConnectContext(ctx, registry)
+}
+
+// load registers the migrations of --dir and calls the Register function of --plugin
+func load(commiter *borm.Commiter, opts options) error {
+	if opts.dir != "" {
+		if err := commiter.RegisterSQLMigrations(os.DirFS(opts.dir), "."); err != nil {
+			return err
+		}
+	}
+	if opts.plugin == "" {
+		return nil
+	}
+
+	p, err := plugin.Open(opts.plugin)
+	if err != nil {
+		return err
+	}
+	symbol, err := p.Lookup("Register")
+	if err != nil {
+		return err
+	}
+	register, ok := symbol.(func(*borm.Commiter) error)
+	if !ok {
+		return fmt.Errorf("%s: Register must be a func(*borm.Commiter) error", opts.plugin)
+	}
+	return register(commiter)
+}
+func migrate(ctx context.Context, commiter *borm.Commiter, opts options, subcommand string, args []string) error {
+	if err := load(commiter, opts); err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "up":
+		return commiter.MigrateUp(ctx)
+	case "down":
+		n := 1
+		if len(args) > 0 {
+			parsed, err := strconv.Atoi(args[0])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid amount of migrations %q", args[0])
+			}
+			n = parsed
+		}
+		return commiter.MigrateDown(ctx, n)
+	case "to":
+		if len(args) == 0 {
+			return errors.New("missing version")
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return commiter.MigrateTo(ctx, version)
+	case "redo":
+		// Only the reverted migration is applied again, pending ones are left to up
+		version, err := lastApplied(ctx, commiter)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return errors.New("no applied migration to redo")
+		}
+		if err := commiter.MigrateDown(ctx, 1); err != nil {
+			return err
+		}
+		return commiter.MigrateTo(ctx, version)
+	case "status":
+		return status(ctx, commiter)
+	case "plan":
+		plan, err := commiter.PlanRelations(ctx)
+		if err != nil {
+			return err
+		}
+		return printPlan(plan, opts)
+	case "relations":
+		return commiter.MigrateRelationsContext(ctx)
+	}
+	return fmt.Errorf("unknown migrate command %q", subcommand)
+}
+
+// lastApplied returns the version MigrateDown reverts first, 0 when no migration is applied
+func lastApplied(ctx context.Context, commiter *borm.Commiter) (int64, error) {
+	states, err := commiter.MigrationStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := int64(0)
+	for _, state := range states {
+		if state.Applied {
+			version = state.Version
+		}
+	}
+	return version, nil
+}
+func status(ctx context.Context, commiter *borm.Commiter) error {
+	states, err := commiter.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, state := range states {
+		status, appliedAt := "pending", ""
+		if state.Applied {
+			status, appliedAt = "applied", state.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if state.Modified {
+			status += " (modified)"
+		}
+		if state.Missing {
+			status += " (missing)"
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", state.Version, state.Name, status, appliedAt)
+	}
+	return writer.Flush()
+}
+func database(ctx context.Context, commiter *borm.Commiter, opts options, subcommand string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing database name")
+	}
+	owner := opts.owner
+	if owner == "" {
+		owner = opts.user
+	}
+	registry := commiter.RegisterDatabase(borm.DatabaseName(args[0]), borm.LookupUser(owner))
+
+	switch subcommand {
+	case "create":
+		plan, err := commiter.PlanDatabase(ctx, registry)
+		if err != nil {
+			return err
+		}
+		return applyPlan(ctx, commiter, plan, opts)
+	case "drop":
+		plan, err := commiter.PlanDropDatabase(ctx, registry)
+		if err != nil {
+			return err
+		}
+		return applyPlan(ctx, commiter, plan, opts)
+	}
+	return fmt.Errorf("unknown db command %q", subcommand)
+}
+func user(ctx context.Context, commiter *borm.Commiter, opts options, subcommand string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing user name")
+	}
+	registry := borm.RegisterUser(args[0], opts.secret)
+
+	switch subcommand {
+	case "create":
+		if opts.secret == "" {
+			return errors.New("missing --secret for the created user")
+		}
+		plan, err := commiter.PlanUsers(ctx, registry)
+		if err != nil {
+			return err
+		}
+		return applyPlan(ctx, commiter, plan, opts)
+	case "drop":
+		// Databases owned by the user are dropped too, --dry-run lists them
+		plan, err := commiter.PlanDropUsers(ctx, registry)
+		if err != nil {
+			return err
+		}
+		return applyPlan(ctx, commiter, plan, opts)
+	}
+	return fmt.Errorf("unknown user command %q", subcommand)
+}
+func applyPlan(ctx context.Context, commiter *borm.Commiter, plan *borm.MigrationPlan, opts options) error {
+	if opts.dryRun {
+		return printPlan(plan, opts)
+	}
+	return commiter.ApplyPlan(ctx, plan)
+}
+func printPlan(plan *borm.MigrationPlan, opts options) error {
+	if !opts.json {
+		fmt.Print(plan.SQL())
+		return nil
+	}
+	data, err := plan.JSON()
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+func introspect(ctx context.Context, commiter *borm.Commiter, opts options) error {
+	schema, err := commiter.Introspect(ctx)
+	if err != nil {
+		return err
+	}
+	source, err := schema.GoSource(opts.packageName)
+	if err != nil {
+		return err
+	}
+	if opts.out == "" {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	return os.WriteFile(opts.out, source, 0o644)
+}
+func env(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
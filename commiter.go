@@ -10,6 +10,8 @@ type Commiter struct {
 
 	// Tells if something was already created or not
 	RegistorCache map[string]bool
+	// Versioned migrations sorted by version
+	versions []*Migration
 
 	*RolesCache
 	*DatabaseRegistry
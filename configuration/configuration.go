@@ -14,3 +14,9 @@ func (c *Configuration) Environment() *EnvironmentSettings {
 func (c *Configuration) Migrations() *MigrationSettings {
 	return migration
 }
+func (c *Configuration) Timestamps() *TimestampSettings {
+	return timestamp
+}
+func (c *Configuration) Logging() *LoggingSettings {
+	return logging
+}
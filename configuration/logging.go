@@ -0,0 +1,18 @@
+package configuration
+
+import "log/slog"
+
+// LoggingSettings holds the logger borm writes its records to. Nothing is logged while it is nil.
+type LoggingSettings struct {
+	Logger *slog.Logger
+}
+
+var logging *LoggingSettings = &LoggingSettings{}
+
+func (l *LoggingSettings) SetLogger(logger *slog.Logger) *LoggingSettings {
+	l.Logger = logger
+	return l
+}
+func (l *LoggingSettings) GetLogger() *slog.Logger {
+	return l.Logger
+}
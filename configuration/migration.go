@@ -4,7 +4,10 @@ type MigrationSettings struct {
 	Enabled  bool
 	Ignore   bool
 	Recreate bool
+	Alter    bool
 	Undo     bool
+	// Foreign keys that close a dependency cycle are added after the tables
+	DeferCycles bool
 }
 
 var migration *MigrationSettings = &MigrationSettings{}
@@ -25,3 +28,16 @@ func (m *MigrationSettings) UndoOnError() *MigrationSettings {
 	m.Undo = true
 	return m
 }
+
+// AlterExisting applies the safe differences between existing tables and their registry, like new columns. Destructive differences are skipped.
+func (m *MigrationSettings) AlterExisting() *MigrationSettings {
+	m.Alter = true
+	return m
+}
+
+// DeferCyclicForeignKeys creates tables that reference each other without the foreign keys that close the cycle.
+// Those foreign keys are added afterwards as DEFERRABLE INITIALLY DEFERRED, otherwise cycles are errors.
+func (m *MigrationSettings) DeferCyclicForeignKeys() *MigrationSettings {
+	m.DeferCycles = true
+	return m
+}
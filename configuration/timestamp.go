@@ -0,0 +1,26 @@
+package configuration
+
+const (
+	CLIENT_CLOCK TimestampSource = iota
+	DATABASE_NOW
+	DATABASE_TRIGGER
+)
+
+// TimestampSource defines who fills the (AUTO CREATE TIME) and (AUTO UPDATE TIME) columns.
+type TimestampSource int
+
+type TimestampSettings struct {
+	Source TimestampSource
+}
+
+var timestamp *TimestampSettings = &TimestampSettings{
+	Source: CLIENT_CLOCK,
+}
+
+func (t *TimestampSettings) SetSource(s TimestampSource) *TimestampSettings {
+	t.Source = s
+	return t
+}
+func (t *TimestampSettings) GetSource() TimestampSource {
+	return t.Source
+}
@@ -0,0 +1,163 @@
+package borm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values are the contype of pg_constraint
+const (
+	CONSTRAINT_PRIMARY_KEY ConstraintKind = "p"
+	CONSTRAINT_UNIQUE      ConstraintKind = "u"
+	CONSTRAINT_FOREIGN_KEY ConstraintKind = "f"
+	CONSTRAINT_CHECK       ConstraintKind = "c"
+	CONSTRAINT_EXCLUDE     ConstraintKind = "x"
+)
+
+type ConstraintKind string
+
+// TableConstraint is a constraint over the whole table, created with it and compared by the schema diff.
+// Unnamed constraints are named like PostgreSQL does: <table>_pkey, <table>_<columns>_key, <table>_<columns>_fkey, <table>_check and <table>_excl,
+// shortened to 63 bytes.
+type TableConstraint struct {
+	Name    string
+	Kind    ConstraintKind
+	Columns []string
+	// Boolean expression of CHECK constraints, elements of EXCLUDE constraints like "room WITH ="
+	Expression string
+	// Index method of EXCLUDE constraints
+	Method    IndexMethod
+	Reference *TableReference
+}
+
+// TableReference is the referenced side of a foreign key over many columns
+type TableReference struct {
+	Table    TableName
+	Columns  []string
+	OnUpdate string
+	OnDelete string
+}
+
+// PrimaryKey declares a primary key over many columns. Fields must not have the PRIMARY KEY constraint.
+func (t *TableRegistry) PrimaryKey(columns ...string) *TableRegistry {
+	for _, field := range t.Fields {
+		if strings.Contains(strings.ToLower(field.Constraints), "primary key") {
+			t.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Table %s already has the primary key %s", t.TableName, field.Name))
+			return t
+		}
+	}
+	return t.addConstraint(&TableConstraint{Kind: CONSTRAINT_PRIMARY_KEY, Columns: columns})
+}
+func (t *TableRegistry) Unique(name string, columns ...string) *TableRegistry {
+	return t.addConstraint(&TableConstraint{Name: name, Kind: CONSTRAINT_UNIQUE, Columns: columns})
+}
+
+// Check declares a CHECK constraint, expression is written as is.
+// Checks are compared by name, a changed expression needs a new name to be migrated.
+func (t *TableRegistry) Check(name string, expression string) *TableRegistry {
+	return t.addConstraint(&TableConstraint{Name: name, Kind: CONSTRAINT_CHECK, Expression: expression})
+}
+
+// Exclude declares an EXCLUDE constraint with elements like "room WITH =" and "during WITH &&". Compared by name like checks.
+func (t *TableRegistry) Exclude(name string, method IndexMethod, elements ...string) *TableRegistry {
+	if len(elements) == 0 {
+		t.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Exclude constraint of table %s must have at least one element", t.TableName))
+		return t
+	}
+	if method != "" {
+		if err := method.validate(); err != nil {
+			t.Error = err
+			return t
+		}
+	}
+	return t.addConstraint(&TableConstraint{Name: name, Kind: CONSTRAINT_EXCLUDE, Method: method, Expression: strings.Join(elements, ", ")})
+}
+
+// ForeignKey declares a foreign key over many columns, referencing the same amount of columns
+func (t *TableRegistry) ForeignKey(name string, columns []string, reference TableReference) *TableRegistry {
+	if len(columns) != len(reference.Columns) {
+		t.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Foreign key of table %s references %d columns with %d", t.TableName, len(reference.Columns), len(columns)))
+		return t
+	}
+	return t.addConstraint(&TableConstraint{Name: name, Kind: CONSTRAINT_FOREIGN_KEY, Columns: columns, Reference: &reference})
+}
+func (t *TableRegistry) addConstraint(constraint *TableConstraint) *TableRegistry {
+	t.Constraints = append(t.Constraints, constraint)
+
+	if constraint.Name != "" {
+		if err := validateIdentifier("constraint", constraint.Name); err != nil {
+			t.Error = err
+			return t
+		}
+	}
+	if constraint.Kind == CONSTRAINT_CHECK && strings.TrimSpace(constraint.Expression) == "" {
+		t.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Check constraint of table %s must have an expression", t.TableName))
+		return t
+	}
+	if constraint.Kind != CONSTRAINT_CHECK && constraint.Kind != CONSTRAINT_EXCLUDE && len(constraint.Columns) == 0 {
+		t.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Constraint of table %s must have at least one column", t.TableName))
+		return t
+	}
+	for _, column := range constraint.Columns {
+		if !t.hasColumn(column) {
+			t.Error = ErrorDescription(ErrNotFound, fmt.Sprintf("Constraint of table %s uses unknown column %s", t.TableName, column))
+			return t
+		}
+	}
+	return t
+}
+
+// tableConstraintDefinitions returns the constraints declared on the table with their generated names
+func (t *TableRegistry) tableConstraintDefinitions() []databaseConstraint {
+	constraints := []databaseConstraint{}
+	for _, constraint := range t.Constraints {
+		definition := databaseConstraint{
+			name:    constraint.Name,
+			kind:    string(constraint.Kind),
+			columns: constraint.Columns,
+		}
+		switch constraint.Kind {
+		case CONSTRAINT_CHECK:
+			definition.expression = fmt.Sprintf("CHECK (%s)", constraint.Expression)
+		case CONSTRAINT_EXCLUDE:
+			definition.expression = fmt.Sprintf("EXCLUDE (%s)", constraint.Expression)
+			if constraint.Method != "" {
+				definition.expression = fmt.Sprintf("EXCLUDE USING %s (%s)", constraint.Method, constraint.Expression)
+			}
+		case CONSTRAINT_FOREIGN_KEY:
+			definition.refTable = string(constraint.Reference.Table)
+			definition.refColumns = constraint.Reference.Columns
+			definition.onUpdate = referenceAction(constraint.Reference.OnUpdate)
+			definition.onDelete = referenceAction(constraint.Reference.OnDelete)
+		}
+		if definition.name == "" {
+			definition.name = t.constraintName(constraint.Kind, constraint.Columns)
+		}
+		constraints = append(constraints, definition)
+	}
+	return constraints
+}
+func (t *TableRegistry) constraintName(kind ConstraintKind, columns []string) string {
+	table := string(t.TableName)
+	switch kind {
+	case CONSTRAINT_PRIMARY_KEY:
+		return objectName(table, "", "pkey")
+	case CONSTRAINT_UNIQUE:
+		return objectName(table, strings.Join(columns, "_"), "key")
+	case CONSTRAINT_FOREIGN_KEY:
+		return objectName(table, strings.Join(columns, "_"), "fkey")
+	case CONSTRAINT_CHECK:
+		return objectName(table, "", "check")
+	}
+	return objectName(table, "", "excl")
+}
+func (t *TableRegistry) validateConstraints() error {
+	names := map[string]bool{}
+	for _, constraint := range t.constraintDefinitions(nil) {
+		if names[constraint.name] {
+			return ErrorDescription(ErrSyntax, fmt.Sprintf("Constraint %s of table %s is declared more than once, constraints of the same kind need different names", constraint.name, t.TableName))
+		}
+		names[constraint.name] = true
+	}
+	return nil
+}
@@ -17,6 +17,24 @@ type DatabaseRegistry struct {
 func (r *DatabaseRegistry) RegisterDatabase(dbname DatabaseName, owner *User) *DatabaseRegistry {
 	return RegisterDatabase(string(dbname), r.Host, owner)
 }
+
+// RegisterTable registers a table of the database, enums of the database used as column types are added to its required types.
+func (r *DatabaseRegistry) RegisterTable(v any) *TableRegistry {
+	table := r.TablesCache.RegisterTable(v)
+	if table.Error == nil {
+		table.inferEnums(r.TypesCache)
+	}
+	return table
+}
+
+// RegisterEnum registers an enum of the database and adds it to the required types of the tables already using it as a column type
+func (r *DatabaseRegistry) RegisterEnum(name string, values ...any) *Enum {
+	enum := r.TypesCache.RegisterEnum(name, values...)
+	for _, table := range *r.TablesCache {
+		table.inferEnums(r.TypesCache)
+	}
+	return enum
+}
 func RegisterDatabase(dbname string, host string, owner *User) *DatabaseRegistry {
 	databaseName := DatabaseName(strings.ToLower(dbname))
 	if database, ok := databases[databaseName]; ok {
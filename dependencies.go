@@ -0,0 +1,163 @@
+package borm
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Noeeekr/borm/configuration"
+)
+
+// tableDependency is an edge of the dependency graph, from a table to a table it needs
+type tableDependency struct {
+	table *TableRegistry
+	// Names of the foreign keys that reference the table. Empty when the dependency only comes from NeedTables.
+	foreignKeys []string
+}
+
+// deferredForeignKeys are the names of the foreign keys of each table added after every table is created, because they close a dependency cycle
+type deferredForeignKeys map[TableName]map[string]bool
+
+// orderedTables sorts the registered tables so each one comes after the tables it needs, without changing them.
+// Dependencies come from NeedTables and from foreign keys.
+// Cycles are errors unless their foreign keys can be deferred, see MigrationSettings.DeferCyclicForeignKeys.
+func (m *Commiter) orderedTables() ([]*TableRegistry, deferredForeignKeys, error) {
+	names := []TableName{}
+	for name := range *m.TablesCache {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[TableName]int{}
+	path := []TableName{}
+	ordered := []*TableRegistry{}
+	deferred := deferredForeignKeys{}
+	deferCycles := configuration.Settings().Migrations().DeferCycles
+
+	var visit func(table *TableRegistry) error
+	visit = func(table *TableRegistry) error {
+		state[table.TableName] = visiting
+		path = append(path, table.TableName)
+		for _, dependency := range m.dependencies(table) {
+			switch state[dependency.table.TableName] {
+			case visited:
+				continue
+			case visiting:
+				if !deferCycles || len(dependency.foreignKeys) == 0 {
+					return cycleError(path, dependency.table.TableName)
+				}
+				// The edge that closes the cycle is removed, its foreign keys are added after every table exists
+				for _, name := range dependency.foreignKeys {
+					if deferred[table.TableName] == nil {
+						deferred[table.TableName] = map[string]bool{}
+					}
+					deferred[table.TableName][name] = true
+				}
+				continue
+			}
+			if err := visit(dependency.table); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[table.TableName] = visited
+		ordered = append(ordered, table)
+		return nil
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			if err := visit((*m.TablesCache)[name]); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return ordered, deferred, nil
+}
+
+// dependencies returns the other registered tables needed by table, sorted by name
+func (m *Commiter) dependencies(table *TableRegistry) []*tableDependency {
+	dependencies := map[TableName]*tableDependency{}
+	add := func(name TableName, foreignKey string) {
+		required, ok := (*m.TablesCache)[name]
+		if !ok || name == table.TableName {
+			return
+		}
+		if dependencies[name] == nil {
+			dependencies[name] = &tableDependency{table: required}
+		}
+		if foreignKey != "" {
+			dependencies[name].foreignKeys = append(dependencies[name].foreignKeys, foreignKey)
+		}
+	}
+
+	for _, required := range table.RequiredTables {
+		add(required.TableName, "")
+	}
+	for _, constraint := range table.constraintDefinitions(nil) {
+		if constraint.kind == "f" {
+			add(TableName(strings.ToLower(constraint.refTable)), constraint.name)
+		}
+	}
+
+	sorted := []*tableDependency{}
+	for _, dependency := range dependencies {
+		sorted = append(sorted, dependency)
+	}
+	slices.SortFunc(sorted, func(a, b *tableDependency) int {
+		return cmp.Compare(a.table.TableName, b.table.TableName)
+	})
+	return sorted
+}
+
+func cycleError(path []TableName, closing TableName) error {
+	start := slices.Index(path, closing)
+	cycle := append(slices.Clone(path[start:]), closing)
+	names := []string{}
+	for _, name := range cycle {
+		names = append(names, string(name))
+	}
+	return ErrorDescription(ErrDependencyCycle, strings.Join(names, " -> "))
+}
+
+// deferredConstraints returns the foreign keys of table left out of its CREATE TABLE because they close a cycle
+func (t *TableRegistry) deferredConstraints(deferred map[string]bool) []databaseConstraint {
+	constraints := []databaseConstraint{}
+	for _, constraint := range t.constraintDefinitions(deferred) {
+		if constraint.deferrable {
+			constraints = append(constraints, constraint)
+		}
+	}
+	return constraints
+}
+
+// migrateDeferredForeignKeys adds the deferred foreign keys of table that don't exist yet
+func (r *Commiter) migrateDeferredForeignKeys(ctx context.Context, t *Transaction, table *TableRegistry, deferredNames map[string]bool) error {
+	deferred := table.deferredConstraints(deferredNames)
+	if len(deferred) == 0 {
+		return nil
+	}
+	existing, err := introspectConstraints(ctx, t, table.TableName)
+	if err != nil {
+		return err
+	}
+
+	for _, constraint := range deferred {
+		if slices.ContainsFunc(existing, constraint.equal) {
+			continue
+		}
+		query := newUnsafeQuery(CREATE, fmt.Sprintf("ALTER TABLE %s ADD %s;", QuoteIdentifier(string(table.TableName)), constraint.definition()))
+		err := doMigration(ctx, t, query)
+		logMigration(ctx, "foreign key", constraint.name, "create", err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
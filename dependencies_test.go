@@ -0,0 +1,114 @@
+package borm
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Noeeekr/borm/configuration"
+)
+
+type Customers struct {
+	Id int
+}
+type Orders struct {
+	Id         int
+	CustomerId int
+}
+type Items struct {
+	Id      int
+	OrderId int
+}
+type Authors struct {
+	Id     int
+	BookId int
+}
+type Books struct {
+	Id       int
+	AuthorId int
+}
+
+func TestOrderedTables(t *testing.T) {
+	tests := []struct {
+		name        string
+		register    func(r *DatabaseRegistry)
+		deferCycles bool
+		want        []TableName
+		deferred    deferredForeignKeys
+		err         string
+	}{
+		{
+			name: "foreign keys and needed tables",
+			register: func(r *DatabaseRegistry) {
+				orders := r.RegisterTable(Orders{}).ForeignKey("", []string{"customerid"}, TableReference{Table: "customers", Columns: []string{"id"}})
+				r.RegisterTable(Items{}).NeedTables(orders)
+				r.RegisterTable(Customers{})
+			},
+			want:     []TableName{"customers", "orders", "items"},
+			deferred: deferredForeignKeys{},
+		},
+		{
+			name: "foreign key cycle",
+			register: func(r *DatabaseRegistry) {
+				r.RegisterTable(Authors{}).ForeignKey("", []string{"bookid"}, TableReference{Table: "books", Columns: []string{"id"}})
+				r.RegisterTable(Books{}).ForeignKey("", []string{"authorid"}, TableReference{Table: "authors", Columns: []string{"id"}})
+			},
+			err: "authors -> books -> authors",
+		},
+		{
+			name: "deferred foreign key cycle",
+			register: func(r *DatabaseRegistry) {
+				r.RegisterTable(Authors{}).ForeignKey("", []string{"bookid"}, TableReference{Table: "books", Columns: []string{"id"}})
+				r.RegisterTable(Books{}).ForeignKey("", []string{"authorid"}, TableReference{Table: "authors", Columns: []string{"id"}})
+			},
+			deferCycles: true,
+			want:        []TableName{"books", "authors"},
+			deferred:    deferredForeignKeys{"books": {"books_authorid_fkey": true}},
+		},
+		{
+			name: "needed tables cycle is never deferred",
+			register: func(r *DatabaseRegistry) {
+				authors := r.RegisterTable(Authors{})
+				books := r.RegisterTable(Books{}).NeedTables(authors)
+				authors.NeedTables(books)
+			},
+			deferCycles: true,
+			err:         "authors -> books -> authors",
+		},
+	}
+
+	migrations := configuration.Settings().Migrations()
+	deferCycles := migrations.DeferCycles
+	defer func() { migrations.DeferCycles = deferCycles }()
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migrations.DeferCycles = test.deferCycles
+			database := RegisterDatabase("ordered_tables_test_"+string(rune('a'+i)), "", nil)
+			test.register(database)
+
+			ordered, deferred, err := newCommiter(database, "", nil).orderedTables()
+			if test.err != "" {
+				if !errors.Is(err, ErrDependencyCycle) || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("orderedTables() error = %v, want a dependency cycle %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("orderedTables() error = %v", err)
+			}
+			names := []TableName{}
+			for _, table := range ordered {
+				names = append(names, table.TableName)
+			}
+			if !slices.Equal(names, test.want) {
+				t.Errorf("orderedTables() = %v, want %v", names, test.want)
+			}
+			if !maps.EqualFunc(deferred, test.deferred, maps.Equal) {
+				t.Errorf("orderedTables() deferred = %v, want %v", deferred, test.deferred)
+			}
+		})
+	}
+}
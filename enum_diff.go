@@ -0,0 +1,340 @@
+package borm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Noeeekr/borm/configuration"
+)
+
+// Rename declares that the value from of the database is now called to. to must be a registered value.
+func (e *Enum) Rename(from string, to string) *Enum {
+	if !e.hasValue(to) {
+		e.registerErrors = ErrorDescription(ErrInvalidType, fmt.Sprintf("Enum %s renames %s to the unregistered value %s", e.Name, from, to))
+		return e
+	}
+	if e.renames == nil {
+		e.renames = map[string]string{}
+	}
+	e.renames[from] = to
+	return e
+}
+
+// Replace declares how rows of a removed value are rewritten. Removing values recreates the type, which is a destructive change.
+func (e *Enum) Replace(removed string, replacement string) *Enum {
+	if !e.hasValue(replacement) {
+		e.registerErrors = ErrorDescription(ErrInvalidType, fmt.Sprintf("Enum %s replaces %s with the unregistered value %s", e.Name, removed, replacement))
+		return e
+	}
+	if e.replacements == nil {
+		e.replacements = map[string]string{}
+	}
+	e.replacements[removed] = replacement
+	return e
+}
+func (e *Enum) hasValue(value string) bool {
+	return slices.Contains(e.labels(), value)
+}
+
+// labels returns the registered values as they are stored by PostgreSQL
+func (e *Enum) labels() []string {
+	labels := []string{}
+	for _, option := range e.options {
+		labels = append(labels, fmt.Sprint(option))
+	}
+	return labels
+}
+
+// diffEnum compares the registered values with pg_enum.
+// New values are added next to their registered neighbours and renames use RENAME VALUE.
+// Removed values need a replacement and recreate the type, converting every column that uses it.
+func diffEnum(ctx context.Context, target doer, enum *Enum) ([]SchemaChange, error) {
+	existing, err := introspectEnumValues(ctx, target, enum.Name)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return []SchemaChange{{
+			Kind:   CHANGE_CREATE_ENUM,
+			Object: string(enum.Name),
+			SQL:    parseCreateEnumQuery(enum).build(),
+		}}, nil
+	}
+
+	labels := enum.labels()
+	removed := []string{}
+	renamed := map[string]string{}
+	for _, value := range existing {
+		if slices.Contains(labels, value) {
+			continue
+		}
+		if to, ok := enum.renames[value]; ok && !slices.Contains(existing, to) {
+			renamed[value] = to
+			continue
+		}
+		if _, ok := enum.replacements[value]; !ok {
+			return nil, ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Enum %s no longer has the value %s", enum.Name, value), "Declare what its rows become with Replace or rename it with Rename")
+		}
+		removed = append(removed, value)
+	}
+	if len(removed) > 0 {
+		return rewriteEnum(ctx, target, enum, renamed)
+	}
+	return alterEnum(enum, existing, renamed), nil
+}
+
+// alterEnum renames the existing values of renamed and adds the missing ones, keeping the registered order
+func alterEnum(enum *Enum, existing []string, renamed map[string]string) []SchemaChange {
+	labels := enum.labels()
+	typeName := QuoteIdentifier(string(enum.Name))
+	changes := []SchemaChange{}
+	current := slices.Clone(existing)
+	for from, to := range renamed {
+		changes = append(changes, SchemaChange{
+			Kind:   CHANGE_RENAME_ENUM_VALUE,
+			Object: string(enum.Name),
+			SQL:    fmt.Sprintf("ALTER TYPE %s RENAME VALUE %s TO %s;", typeName, QuoteLiteral(from), QuoteLiteral(to)),
+		})
+		current[slices.Index(current, from)] = to
+	}
+	slices.SortFunc(changes, func(a, b SchemaChange) int {
+		return strings.Compare(a.SQL, b.SQL)
+	})
+
+	for i, label := range labels {
+		if slices.Contains(current, label) {
+			continue
+		}
+		// Placed after the previous registered value, or before the next one when it is the first
+		position := ""
+		if i > 0 {
+			position = " AFTER " + QuoteLiteral(labels[i-1])
+		} else if next := slices.IndexFunc(labels, func(l string) bool { return slices.Contains(current, l) }); next >= 0 {
+			position = " BEFORE " + QuoteLiteral(labels[next])
+		}
+		changes = append(changes, SchemaChange{
+			Kind:   CHANGE_ADD_ENUM_VALUE,
+			Object: string(enum.Name),
+			SQL:    fmt.Sprintf("ALTER TYPE %s ADD VALUE %s%s;", typeName, QuoteLiteral(label), position),
+		})
+		current = append(current, label)
+	}
+	return changes
+}
+
+// rewriteEnum recreates an enum without its removed values. Columns, arrays of the enum included, are converted with their values renamed or replaced.
+// Defaults must be literals, like 'apple' or '{apple}', so their values are converted too.
+// Each statement is a destructive change of its own, statements can't be prepared together.
+func rewriteEnum(ctx context.Context, target doer, enum *Enum, renamed map[string]string) ([]SchemaChange, error) {
+	columns, err := introspectEnumColumns(ctx, target, enum.Name)
+	if err != nil {
+		return nil, err
+	}
+	rewritten := maps.Clone(renamed)
+	maps.Copy(rewritten, enum.replacements)
+	rewrite := func(label string) string {
+		if to, ok := rewritten[label]; ok {
+			return to
+		}
+		return label
+	}
+
+	old := string(enum.Name) + "_old"
+	typeName, oldName := QuoteIdentifier(string(enum.Name)), QuoteIdentifier(old)
+	statements := []string{
+		fmt.Sprintf("ALTER TYPE %s RENAME TO %s;", typeName, oldName),
+		parseCreateEnumQuery(enum).build(),
+	}
+
+	labels := slices.Sorted(maps.Keys(rewritten))
+	for _, column := range columns {
+		table, name := QuoteIdentifier(column.table), QuoteIdentifier(column.name)
+		columnType := typeName
+		using := fmt.Sprintf("%s::text", name)
+		if column.array {
+			// Subqueries are not allowed in USING, elements are rewritten one value at a time
+			columnType += "[]"
+			using = fmt.Sprintf("%s::text[]", name)
+			for _, label := range labels {
+				using = fmt.Sprintf("array_replace(%s, %s, %s)", using, QuoteLiteral(label), QuoteLiteral(rewritten[label]))
+			}
+		} else if len(labels) > 0 {
+			whens := []string{}
+			for _, label := range labels {
+				whens = append(whens, fmt.Sprintf("WHEN %s THEN %s", QuoteLiteral(label), QuoteLiteral(rewritten[label])))
+			}
+			using = fmt.Sprintf("CASE %s::text %s ELSE %s::text END", name, strings.Join(whens, " "), name)
+		}
+
+		// Defaults can't be cast between enums, they are dropped during the conversion
+		def := ""
+		if column.def != "" {
+			var ok bool
+			if def, ok = rewriteEnumDefault(column.def, columnType, column.array, rewrite); !ok {
+				return nil, ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Unable to convert the default %s of %s.%s to the new enum %s", column.def, column.table, column.name, enum.Name), "Only literal defaults are converted, drop the default first")
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT;", table, name))
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING (%s)::%s;", table, name, columnType, using, columnType))
+		if def != "" {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;", table, name, def))
+		}
+	}
+	statements = append(statements, fmt.Sprintf("DROP TYPE %s;", oldName))
+
+	changes := []SchemaChange{}
+	for _, statement := range statements {
+		changes = append(changes, SchemaChange{
+			Kind:        CHANGE_REWRITE_ENUM,
+			Object:      string(enum.Name),
+			SQL:         statement,
+			Destructive: true,
+		})
+	}
+	return changes, nil
+}
+
+var enumDefaultPattern = regexp.MustCompile(`^'((?:[^']|'')*)'::`)
+
+// rewriteEnumDefault rewrites the values of a literal default like 'apple'::fruits, or '{apple}'::fruits[] for arrays, and casts it to columnType.
+// Returns false when the default is not a literal.
+func rewriteEnumDefault(def string, columnType string, array bool, rewrite func(string) string) (string, bool) {
+	match := enumDefaultPattern.FindStringSubmatch(def)
+	if match == nil {
+		return "", false
+	}
+	literal := strings.ReplaceAll(match[1], "''", "'")
+	if !array {
+		return QuoteLiteral(rewrite(literal)) + "::" + columnType, true
+	}
+	// Quoted elements are left to the user
+	if !strings.HasPrefix(literal, "{") || !strings.HasSuffix(literal, "}") || strings.ContainsAny(literal, `"\`) {
+		return "", false
+	}
+	elements := strings.TrimSuffix(strings.TrimPrefix(literal, "{"), "}")
+	if elements != "" {
+		values := strings.Split(elements, ",")
+		for i, value := range values {
+			values[i] = rewrite(value)
+		}
+		elements = strings.Join(values, ",")
+	}
+	return QuoteLiteral("{"+elements+"}") + "::" + columnType, true
+}
+
+// introspectEnumValues returns the values of an enum in their order. Returns nil if the enum doesn't exist.
+func introspectEnumValues(ctx context.Context, target doer, name TypName) ([]string, error) {
+	var values []string
+	query := newUnsafeQuery(SELECT, `SELECT e.enumlabel
+FROM pg_catalog.pg_type t
+JOIN pg_catalog.pg_enum e ON e.enumtypid = t.oid
+JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+WHERE t.typname = $1 AND n.nspname = current_schema()
+ORDER BY e.enumsortorder`)
+	query.CurrentValues = append(query.CurrentValues, name)
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				return false, err
+			}
+			values = append(values, value)
+		}
+		return true, rows.Err()
+	})
+	if err := target.DoContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+type enumColumn struct {
+	table string
+	name  string
+	// The column is an array of the enum
+	array bool
+	def   string
+}
+
+// introspectEnumColumns returns the table columns whose type is the enum or an array of it
+func introspectEnumColumns(ctx context.Context, target doer, name TypName) ([]enumColumn, error) {
+	columns := []enumColumn{}
+	query := newUnsafeQuery(SELECT, `SELECT c.relname, a.attname, t.typcategory = 'A', COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+FROM pg_catalog.pg_attribute a
+JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+JOIN pg_catalog.pg_type t ON t.oid = a.atttypid
+JOIN pg_catalog.pg_type e ON e.oid = CASE WHEN t.typcategory = 'A' THEN t.typelem ELSE t.oid END
+JOIN pg_catalog.pg_namespace n ON n.oid = e.typnamespace
+LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE e.typname = $1 AND n.nspname = current_schema() AND c.relkind = 'r' AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY c.relname, a.attnum`)
+	query.CurrentValues = append(query.CurrentValues, name)
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var column enumColumn
+			if err := rows.Scan(&column.table, &column.name, &column.array, &column.def); err != nil {
+				return false, err
+			}
+			columns = append(columns, column)
+		}
+		return true, rows.Err()
+	})
+	if err := target.DoContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// alterEnum applies the safe changes of an existing enum. Rewrites that remove values are only logged.
+// Renamed and added values are left to migrateEnumValues.
+func (r *Commiter) alterEnum(ctx context.Context, t *Transaction, enum *Enum) error {
+	changes, err := diffEnum(ctx, t, enum)
+	if err != nil {
+		return err
+	}
+	return applySchemaChanges(ctx, t, slices.DeleteFunc(changes, SchemaChange.enumValue), false)
+}
+
+// migrateEnumValues renames and adds the values of the existing enums used by the tables, only when AlterExisting is set.
+// It runs in its own transaction before the tables, PostgreSQL doesn't allow using a value in the transaction that adds it, like in a DEFAULT or a CHECK.
+func (r *Commiter) migrateEnumValues(ctx context.Context, t *Transaction) error {
+	configuration := configuration.Settings().Migrations()
+	if !configuration.Alter || configuration.Ignore || configuration.Recreate {
+		return nil
+	}
+	tables, _, err := r.orderedTables()
+	if err != nil {
+		return err
+	}
+
+	enums := map[TypName]bool{}
+	for _, table := range tables {
+		for _, typ := range table.RequiredTypes {
+			enum, ok := typ.(*Enum)
+			if !ok || enums[enum.Name] || r.RegistorCache[string(enum.Name)] {
+				continue
+			}
+			enums[enum.Name] = true
+			if enum.registerErrors != nil {
+				return enum.registerErrors
+			}
+
+			changes, err := diffEnum(ctx, t, enum)
+			if err != nil {
+				return err
+			}
+			changes = slices.DeleteFunc(changes, func(c SchemaChange) bool { return !c.enumValue() })
+			if err := applySchemaChanges(ctx, t, changes, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
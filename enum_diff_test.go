@@ -0,0 +1,101 @@
+package borm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAlterEnum(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   []any
+		existing []string
+		renamed  map[string]string
+		want     []string
+	}{
+		{
+			name:     "unchanged",
+			labels:   []any{"apple", "banana"},
+			existing: []string{"apple", "banana"},
+			want:     []string{},
+		},
+		{
+			name:     "appended value",
+			labels:   []any{"apple", "banana", "cherry"},
+			existing: []string{"apple", "banana"},
+			want:     []string{`ALTER TYPE "fruits" ADD VALUE 'cherry' AFTER 'banana';`},
+		},
+		{
+			name:     "first value",
+			labels:   []any{"apple", "banana"},
+			existing: []string{"banana"},
+			want:     []string{`ALTER TYPE "fruits" ADD VALUE 'apple' BEFORE 'banana';`},
+		},
+		{
+			name:     "values in the middle",
+			labels:   []any{"apple", "banana", "cherry", "grape"},
+			existing: []string{"apple", "grape"},
+			want: []string{
+				`ALTER TYPE "fruits" ADD VALUE 'banana' AFTER 'apple';`,
+				`ALTER TYPE "fruits" ADD VALUE 'cherry' AFTER 'banana';`,
+			},
+		},
+		{
+			name:     "renames come first in a stable order",
+			labels:   []any{"apple", "banana", "cherry"},
+			existing: []string{"pear", "grape"},
+			renamed:  map[string]string{"pear": "apple", "grape": "banana"},
+			want: []string{
+				`ALTER TYPE "fruits" RENAME VALUE 'grape' TO 'banana';`,
+				`ALTER TYPE "fruits" RENAME VALUE 'pear' TO 'apple';`,
+				`ALTER TYPE "fruits" ADD VALUE 'cherry' AFTER 'banana';`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enum := newTypesCache().RegisterEnum("fruits", test.labels...)
+			statements := []string{}
+			for _, change := range alterEnum(enum, test.existing, test.renamed) {
+				statements = append(statements, change.SQL)
+			}
+			if !slices.Equal(statements, test.want) {
+				t.Errorf("alterEnum() = %q, want %q", statements, test.want)
+			}
+		})
+	}
+}
+
+func TestRewriteEnumDefault(t *testing.T) {
+	rewrite := func(label string) string {
+		if label == "grape" {
+			return "banana"
+		}
+		return label
+	}
+	tests := []struct {
+		name       string
+		def        string
+		columnType string
+		array      bool
+		want       string
+		ok         bool
+	}{
+		{"value", "'grape'::fruits", `"fruits"`, false, `'banana'::"fruits"`, true},
+		{"kept value", "'apple'::fruits", `"fruits"`, false, `'apple'::"fruits"`, true},
+		{"quote in value", "'it''s'::fruits", `"fruits"`, false, `'it''s'::"fruits"`, true},
+		{"array", "'{grape,apple}'::fruits[]", `"fruits"[]`, true, `'{banana,apple}'::"fruits"[]`, true},
+		{"empty array", "'{}'::fruits[]", `"fruits"[]`, true, `'{}'::"fruits"[]`, true},
+		{"quoted array elements", `'{"grape"}'::fruits[]`, `"fruits"[]`, true, "", false},
+		{"array constructor", "ARRAY['grape'::fruits]", `"fruits"[]`, true, "", false},
+		{"function", "default_fruit()", `"fruits"`, false, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := rewriteEnumDefault(test.def, test.columnType, test.array, rewrite)
+			if got != test.want || ok != test.ok {
+				t.Errorf("rewriteEnumDefault(%q) = %q, %v, want %q, %v", test.def, got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package borm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// EnumValues is implemented by go enum types that list their values, like UserRole. EnumValue uses it to reject unknown values.
+type EnumValues[T ~string] interface {
+	EnumValues() []T
+}
+
+// EnumValue is a nullable value of a go enum type, scanned from and written to enum columns.
+// The registry type is named Enum, so the generic type is EnumValue[T].
+type EnumValue[T ~string] struct {
+	Val   T
+	Valid bool
+}
+
+func NewEnumValue[T ~string](value T) EnumValue[T] {
+	return EnumValue[T]{Val: value, Valid: true}
+}
+func (e *EnumValue[T]) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		e.Val, e.Valid = "", false
+		return nil
+	case string:
+		e.Val = T(src)
+	case []byte:
+		e.Val = T(src)
+	default:
+		return ErrorDescription(ErrInvalidType, fmt.Sprintf("Unable to scan %T into %T", src, e.Val))
+	}
+	e.Valid = true
+	return e.validate()
+}
+func (e EnumValue[T]) Value() (driver.Value, error) {
+	if !e.Valid {
+		return nil, nil
+	}
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	return string(e.Val), nil
+}
+func (e EnumValue[T]) validate() error {
+	values, ok := any(e.Val).(EnumValues[T])
+	if !ok || slices.Contains(values.EnumValues(), e.Val) {
+		return nil
+	}
+	allowed := []string{}
+	for _, value := range values.EnumValues() {
+		allowed = append(allowed, string(value))
+	}
+	return ErrorDescription(ErrInvalidType, fmt.Sprintf("%s is not a value of %T", e.Val, e.Val), fmt.Sprintf("Allowed values: %s", strings.Join(allowed, ", ")))
+}
+
+// enumOf returns the required enum used as the type of field, nil if the field is not an enum
+func (t *TableRegistry) enumOf(field *TableFieldValues) *Enum {
+	for _, typ := range t.RequiredTypes {
+		if enum, ok := typ.(*Enum); ok && string(enum.Name) == strings.Trim(strings.ToLower(field.Type), `"`) {
+			return enum
+		}
+	}
+	return nil
+}
+
+// validateEnumValues returns ErrInvalidType if field is a column of a required enum, declared with NeedRoles or found by its (TYPE) tag, and a value is not one of the enum values.
+// Fields can be aliased, unknown fields and tables are left to the other validations.
+func (q *Query) validateEnumValues(field string, values ...any) error {
+	alias, column, aliased := strings.Cut(field, ".")
+	if !aliased {
+		alias, column = "", field
+	}
+	table := q.tableAliases[alias]
+	if table == nil && !aliased {
+		table = q.TableRegistry
+	}
+	if table == nil {
+		return nil
+	}
+	tableField, ok := table.Fields[TableFieldName(strings.Trim(column, `"`))]
+	if !ok {
+		return nil
+	}
+	enum := table.enumOf(tableField)
+	if enum == nil {
+		return nil
+	}
+
+	labels := enum.labels()
+	for _, value := range values {
+		label, ok, err := enumLabel(value)
+		if err != nil {
+			return err
+		}
+		if ok && !slices.Contains(labels, label) {
+			return ErrorDescription(
+				ErrInvalidType,
+				fmt.Sprintf("%s is not a value of %s.%s", label, table.TableName, tableField.Name),
+				fmt.Sprintf("Allowed values: %s", strings.Join(labels, ", ")),
+			)
+		}
+	}
+	return nil
+}
+
+// enumLabel returns the text PostgreSQL would store for value. Returns false for NULL values.
+func enumLabel(value any) (string, bool, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil || v == nil {
+			return "", false, err
+		}
+		value = v
+	}
+	reflected := reflect.ValueOf(value)
+	for reflected.Kind() == reflect.Pointer {
+		if reflected.IsNil() {
+			return "", false, nil
+		}
+		reflected = reflected.Elem()
+	}
+	if !reflected.IsValid() {
+		return "", false, nil
+	}
+	if bytes, ok := reflected.Interface().([]byte); ok {
+		return string(bytes), true, nil
+	}
+	return fmt.Sprint(reflected.Interface()), true, nil
+}
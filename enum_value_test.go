@@ -0,0 +1,74 @@
+package borm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+type fruit string
+
+func (fruit) EnumValues() []fruit {
+	return []fruit{"apple", "banana"}
+}
+
+type label string
+
+func TestEnumValueScan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  any
+		want EnumValue[fruit]
+		err  error
+	}{
+		{"null", nil, EnumValue[fruit]{}, nil},
+		{"string", "apple", EnumValue[fruit]{Val: "apple", Valid: true}, nil},
+		{"bytes", []byte("banana"), EnumValue[fruit]{Val: "banana", Valid: true}, nil},
+		{"unknown value", "cherry", EnumValue[fruit]{Val: "cherry", Valid: true}, ErrInvalidType},
+		{"unsupported type", 1, EnumValue[fruit]{Val: "apple", Valid: true}, ErrInvalidType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := NewEnumValue[fruit]("apple")
+			err := value.Scan(test.src)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Scan(%v) error = %v, want %v", test.src, err, test.err)
+			}
+			if value != test.want {
+				t.Errorf("Scan(%v) = %+v, want %+v", test.src, value, test.want)
+			}
+		})
+	}
+}
+
+func TestEnumValueScanWithoutValues(t *testing.T) {
+	var value EnumValue[label]
+	if err := value.Scan("anything"); err != nil || value != NewEnumValue[label]("anything") {
+		t.Errorf("Scan() = %+v, %v, want any value of a type without EnumValues", value, err)
+	}
+}
+
+func TestEnumValueValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value EnumValue[fruit]
+		want  driver.Value
+		err   error
+	}{
+		{"null", EnumValue[fruit]{}, nil, nil},
+		{"null ignores the value", EnumValue[fruit]{Val: "cherry"}, nil, nil},
+		{"value", NewEnumValue[fruit]("banana"), "banana", nil},
+		{"unknown value", NewEnumValue[fruit]("cherry"), nil, ErrInvalidType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.value.Value()
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Value() error = %v, want %v", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("Value() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
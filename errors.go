@@ -12,6 +12,8 @@ var (
 	ErrInvalidMethodChain error = errors.New("invalid method chaining")
 	ErrInvalidType        error = errors.New("invalid type")
 	ErrSyntax             error = errors.New("syntax error")
+	ErrInvalidIdentifier  error = errors.New("invalid identifier")
+	ErrDependencyCycle    error = errors.New("dependency cycle")
 
 	ErrNotFound error = errors.New("not found")
 	ErrFound    error = errors.New("found")
@@ -21,6 +23,11 @@ var (
 	ErrFailedTransactionStart    error = errors.New("failed transaction start")
 	ErrFailedTransactionCommit   error = errors.New("failed transaction commit")
 	ErrFailedTransactionRollback error = errors.New("failed transaction rollback")
+	ErrTransactionDone           error = errors.New("transaction already committed or rolled back")
+	ErrCallbackPanic             error = errors.New("transaction callback panicked")
+
+	ErrInvalidMigration error = errors.New("invalid migration")
+	ErrChecksumMismatch error = errors.New("migration checksum mismatch")
 
 	ErrBadConnection error = errors.New("bad connection")
 	ErrUnexpected    error = errors.New("unexpected")
@@ -0,0 +1,132 @@
+package borm
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Execution describes a query sent to the database.
+//
+// Middlewares can change SQL and Args before calling the next executor.
+// Duration, RowsAffected and Error are filled after the query reaches the database.
+type Execution struct {
+	Type  QueryType
+	Table TableName
+	SQL   string
+	Args  []any
+
+	Duration time.Duration
+	// Rows changed by the query. -1 for queries with a scanner, their rows are consumed by the scanner.
+	RowsAffected int64
+	Error        error
+
+	query *Query
+	found bool
+	// Tells if the error happened while running the query, which aborts transactions
+	failed bool
+}
+
+// Executor sends an execution to the database and returns its error.
+type Executor func(ctx context.Context, execution *Execution) error
+
+// Middleware wraps the next executor of the chain. It must call next for the query to reach the database.
+type Middleware func(next Executor) Executor
+
+// preparer is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ExecutionPipeline holds the middlewares shared by a TransactionFactory and its transactions
+type ExecutionPipeline struct {
+	mutex       sync.RWMutex
+	middlewares []Middleware
+	monitor     *QueryMonitor
+}
+
+func newExecutionPipeline() *ExecutionPipeline {
+	return &ExecutionPipeline{
+		monitor: newQueryMonitor(),
+	}
+}
+
+// Use appends middlewares to the chain. The first registered middleware is the first to see an execution.
+func (p *ExecutionPipeline) Use(middlewares ...Middleware) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.middlewares = append(p.middlewares, middlewares...)
+}
+
+// execute sends the query through the middleware chain to target
+func (p *ExecutionPipeline) execute(ctx context.Context, target preparer, query *Query) (*Execution, error) {
+	execution := newExecution(query)
+
+	p.mutex.RLock()
+	executor := databaseExecutor(target)
+	for i := len(p.middlewares) - 1; i >= 0; i-- {
+		executor = p.middlewares[i](executor)
+	}
+	p.mutex.RUnlock()
+
+	err := executor(ctx, execution)
+	p.monitor.record(ctx, execution, err)
+	return execution, err
+}
+
+func newExecution(query *Query) *Execution {
+	execution := &Execution{
+		Type:  query.Type,
+		SQL:   query.build(),
+		Args:  query.CurrentValues,
+		query: query,
+	}
+	if query.QueryValidator != nil && query.TableRegistry != nil {
+		execution.Table = query.TableRegistry.TableName
+	}
+	return execution
+}
+
+// databaseExecutor is the last executor of every chain, it prepares and runs the query on target
+func databaseExecutor(target preparer) Executor {
+	return func(ctx context.Context, e *Execution) error {
+		start := time.Now()
+		e.Error = e.run(ctx, target)
+		e.Duration = time.Since(start)
+		logExecution(ctx, e)
+		return e.Error
+	}
+}
+func (e *Execution) run(ctx context.Context, target preparer) error {
+	if e.query.unprepared {
+		return e.result(target.ExecContext(ctx, e.SQL, e.Args...))
+	}
+	stmt, err := target.PrepareContext(ctx, e.SQL)
+	if err != nil {
+		return ErrorJoin(ErrSyntax, err)
+	}
+	defer stmt.Close()
+
+	if e.query.RowsScanner == nil {
+		return e.result(stmt.ExecContext(ctx, e.Args...))
+	}
+
+	e.RowsAffected = -1
+	rows, err := stmt.QueryContext(ctx, e.Args...)
+	if err != nil {
+		e.failed = true
+		return ErrorJoin(ErrFailedTransaction, err)
+	}
+	e.found, err = e.query.Scan(rows)
+	return err
+}
+func (e *Execution) result(result sql.Result, err error) error {
+	if err != nil {
+		e.failed = true
+		return ErrorJoin(ErrFailedTransaction, err)
+	}
+	e.RowsAffected, _ = result.RowsAffected()
+	return nil
+}
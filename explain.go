@@ -0,0 +1,288 @@
+package borm
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EXPLAIN_TEXT ExplainFormat = iota
+	EXPLAIN_JSON
+)
+
+// ExplainFormat is the output format requested from the database. Both formats are parsed into the same plan tree.
+type ExplainFormat int
+
+type ExplainOptions struct {
+	// Runs the query to collect actual times and rows. Writes are rolled back.
+	Analyze bool
+	Buffers bool
+	Format  ExplainFormat
+}
+
+// Explainer is a target queries can be explained on, either a Commiter or a Transaction.
+type Explainer interface {
+	explain(ctx context.Context, query *Query, rollback bool) error
+}
+
+// Explanation is the parsed output of EXPLAIN
+type Explanation struct {
+	Plan *QueryPlan
+	// Only set with ANALYZE
+	PlanningTime  time.Duration
+	ExecutionTime time.Duration
+	// Output as returned by the database
+	Raw string
+}
+
+// QueryPlan is a node of the plan tree. Costs are in planner units, actual times in milliseconds.
+type QueryPlan struct {
+	NodeType    string
+	Relation    string
+	StartupCost float64
+	TotalCost   float64
+	PlanRows    int64
+	PlanWidth   int64
+
+	// Only set with ANALYZE. Actual rows are an average per loop, PostgreSQL 18 prints them with decimals.
+	ActualStartupTime float64
+	ActualTotalTime   float64
+	ActualRows        float64
+	ActualLoops       int64
+
+	Children []*QueryPlan
+}
+
+// Explain asks the database for the plan of the query.
+//
+// With ANALYZE the query is executed, queries other than SELECT run inside a transaction or savepoint that is always rolled back.
+func (q *Query) Explain(ctx context.Context, target Explainer, options ExplainOptions) (*Explanation, error) {
+	if q.Error != nil {
+		return nil, q.Error
+	}
+	if err := q.isValid(); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	query := newUnsafeQuery(SELECT, options.statement()+q.build())
+	query.CurrentValues = q.CurrentValues
+	query.timeout = q.timeout
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				return false, err
+			}
+			lines = append(lines, line)
+		}
+		return len(lines) > 0, rows.Err()
+	})
+
+	if err := target.explain(ctx, query, options.Analyze && q.Type != SELECT); err != nil {
+		return nil, err
+	}
+
+	raw := strings.Join(lines, "\n")
+	if options.Format == EXPLAIN_JSON {
+		return parseJSONPlan(raw)
+	}
+	return parseTextPlan(raw)
+}
+func (o ExplainOptions) statement() string {
+	options := []string{}
+	if o.Analyze {
+		options = append(options, "ANALYZE")
+	}
+	if o.Buffers {
+		options = append(options, "BUFFERS")
+	}
+	if o.Format == EXPLAIN_JSON {
+		options = append(options, "FORMAT JSON")
+	} else {
+		options = append(options, "FORMAT TEXT")
+	}
+	return "EXPLAIN (" + strings.Join(options, ", ") + ") "
+}
+func (m *TransactionFactory) explain(ctx context.Context, query *Query, rollback bool) error {
+	if !rollback {
+		return m.DoContext(ctx, query)
+	}
+
+	tx, err := m.StartTxContext(ctx)
+	if err != nil {
+		return err
+	}
+	err = tx.DoContext(ctx, query)
+	if tx.active() {
+		return errors.Join(err, tx.Rollback())
+	}
+	return err
+}
+func (t *Transaction) explain(ctx context.Context, query *Query, rollback bool) error {
+	if !rollback {
+		return t.DoContext(ctx, query)
+	}
+
+	nested, err := t.nest(ctx)
+	if err != nil {
+		return err
+	}
+	err = nested.DoContext(ctx, query)
+	if nested.active() {
+		return errors.Join(err, nested.Rollback())
+	}
+	return err
+}
+
+type jsonPlan struct {
+	NodeType          string     `json:"Node Type"`
+	Relation          string     `json:"Relation Name"`
+	StartupCost       float64    `json:"Startup Cost"`
+	TotalCost         float64    `json:"Total Cost"`
+	PlanRows          int64      `json:"Plan Rows"`
+	PlanWidth         int64      `json:"Plan Width"`
+	ActualStartupTime float64    `json:"Actual Startup Time"`
+	ActualTotalTime   float64    `json:"Actual Total Time"`
+	ActualRows        float64    `json:"Actual Rows"`
+	ActualLoops       int64      `json:"Actual Loops"`
+	Plans             []jsonPlan `json:"Plans"`
+}
+
+func (p jsonPlan) queryPlan() *QueryPlan {
+	plan := &QueryPlan{
+		NodeType:          p.NodeType,
+		Relation:          p.Relation,
+		StartupCost:       p.StartupCost,
+		TotalCost:         p.TotalCost,
+		PlanRows:          p.PlanRows,
+		PlanWidth:         p.PlanWidth,
+		ActualStartupTime: p.ActualStartupTime,
+		ActualTotalTime:   p.ActualTotalTime,
+		ActualRows:        p.ActualRows,
+		ActualLoops:       p.ActualLoops,
+	}
+	for _, child := range p.Plans {
+		plan.Children = append(plan.Children, child.queryPlan())
+	}
+	return plan
+}
+func parseJSONPlan(raw string) (*Explanation, error) {
+	var output []struct {
+		Plan          jsonPlan `json:"Plan"`
+		PlanningTime  float64  `json:"Planning Time"`
+		ExecutionTime float64  `json:"Execution Time"`
+	}
+	if err := json.Unmarshal([]byte(raw), &output); err != nil {
+		return nil, ErrorDescription(ErrFailedOperation, "Unable to parse the plan", err.Error())
+	}
+	if len(output) == 0 {
+		return nil, ErrorDescription(ErrFailedOperation, "Unable to parse the plan", "Empty output")
+	}
+	return &Explanation{
+		Plan:          output[0].Plan.queryPlan(),
+		PlanningTime:  milliseconds(output[0].PlanningTime),
+		ExecutionTime: milliseconds(output[0].ExecutionTime),
+		Raw:           raw,
+	}, nil
+}
+
+var (
+	planCostPattern   = regexp.MustCompile(`\(cost=([\d.]+)\.\.([\d.]+) rows=(\d+) width=(\d+)\)`)
+	planActualPattern = regexp.MustCompile(`\(actual (?:time=([\d.]+)\.\.([\d.]+) )?rows=([\d.]+) loops=(\d+)\)`)
+	planTimePattern   = regexp.MustCompile(`^(Planning|Execution) Time: ([\d.]+) ms$`)
+)
+
+// parseTextPlan builds the tree from the indentation of the "->" arrows. Lines that are not nodes, like filters, are skipped.
+func parseTextPlan(raw string) (*Explanation, error) {
+	type level struct {
+		indent int
+		plan   *QueryPlan
+	}
+	explanation := &Explanation{Raw: raw}
+	stack := []level{}
+
+	for _, line := range strings.Split(raw, "\n") {
+		if match := planTimePattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			value, _ := strconv.ParseFloat(match[2], 64)
+			if match[1] == "Planning" {
+				explanation.PlanningTime = milliseconds(value)
+			} else {
+				explanation.ExecutionTime = milliseconds(value)
+			}
+			continue
+		}
+
+		cost := planCostPattern.FindStringSubmatchIndex(line)
+		if cost == nil {
+			continue
+		}
+		indent := strings.Index(line, "->")
+		if indent < 0 && explanation.Plan != nil {
+			continue
+		}
+
+		plan := parseTextNode(line, cost)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			if explanation.Plan != nil {
+				return nil, ErrorDescription(ErrFailedOperation, "Unable to parse the plan", "Node without parent: "+line)
+			}
+			explanation.Plan = plan
+		} else {
+			parent := stack[len(stack)-1].plan
+			parent.Children = append(parent.Children, plan)
+		}
+		stack = append(stack, level{indent: indent, plan: plan})
+	}
+
+	if explanation.Plan == nil {
+		return nil, ErrorDescription(ErrFailedOperation, "Unable to parse the plan", "No plan nodes found")
+	}
+	return explanation, nil
+}
+
+// parseTextNode reads a node line like "->  Seq Scan on users  (cost=0.00..1.01 rows=1 width=4)"
+func parseTextNode(line string, cost []int) *QueryPlan {
+	label := strings.TrimSpace(line[:cost[0]])
+	label = strings.TrimSpace(strings.TrimPrefix(label, "->"))
+
+	plan := &QueryPlan{NodeType: label}
+	for _, separator := range []string{" using ", " on "} {
+		if before, after, found := strings.Cut(label, separator); found {
+			plan.NodeType = before
+			if separator == " on " {
+				plan.Relation = strings.Fields(after)[0]
+			} else if _, relation, found := strings.Cut(after, " on "); found {
+				plan.Relation = strings.Fields(relation)[0]
+			}
+			break
+		}
+	}
+	plan.Relation = strings.Trim(plan.Relation, `"`)
+
+	plan.StartupCost, _ = strconv.ParseFloat(line[cost[2]:cost[3]], 64)
+	plan.TotalCost, _ = strconv.ParseFloat(line[cost[4]:cost[5]], 64)
+	plan.PlanRows, _ = strconv.ParseInt(line[cost[6]:cost[7]], 10, 64)
+	plan.PlanWidth, _ = strconv.ParseInt(line[cost[8]:cost[9]], 10, 64)
+
+	if actual := planActualPattern.FindStringSubmatch(line); actual != nil {
+		plan.ActualStartupTime, _ = strconv.ParseFloat(actual[1], 64)
+		plan.ActualTotalTime, _ = strconv.ParseFloat(actual[2], 64)
+		plan.ActualRows, _ = strconv.ParseFloat(actual[3], 64)
+		plan.ActualLoops, _ = strconv.ParseInt(actual[4], 10, 64)
+	}
+	return plan
+}
+func milliseconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Millisecond))
+}
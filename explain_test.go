@@ -0,0 +1,140 @@
+package borm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTextPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want *Explanation
+	}{
+		{
+			name: "single node",
+			raw:  `Seq Scan on users  (cost=0.00..22.70 rows=1270 width=36)`,
+			want: &Explanation{Plan: &QueryPlan{NodeType: "Seq Scan", Relation: "users", TotalCost: 22.70, PlanRows: 1270, PlanWidth: 36}},
+		},
+		{
+			name: "nested nodes",
+			raw: `Hash Join  (cost=1.05..2.16 rows=4 width=68)
+  Hash Cond: (orders.user_id = users.id)
+  ->  Seq Scan on orders  (cost=0.00..1.04 rows=4 width=36)
+  ->  Hash  (cost=1.02..1.02 rows=2 width=36)
+        ->  Index Scan using users_pkey on "users"  (cost=0.00..1.02 rows=2 width=36)
+              Index Cond: (id = 1)`,
+			want: &Explanation{Plan: &QueryPlan{
+				NodeType: "Hash Join", StartupCost: 1.05, TotalCost: 2.16, PlanRows: 4, PlanWidth: 68,
+				Children: []*QueryPlan{
+					{NodeType: "Seq Scan", Relation: "orders", TotalCost: 1.04, PlanRows: 4, PlanWidth: 36},
+					{NodeType: "Hash", StartupCost: 1.02, TotalCost: 1.02, PlanRows: 2, PlanWidth: 36, Children: []*QueryPlan{
+						{NodeType: "Index Scan", Relation: "users", TotalCost: 1.02, PlanRows: 2, PlanWidth: 36},
+					}},
+				},
+			}},
+		},
+		{
+			name: "analyze",
+			raw: `Seq Scan on users  (cost=0.00..1.01 rows=1 width=4) (actual time=0.010..0.012 rows=3 loops=1)
+Planning Time: 0.050 ms
+Execution Time: 1.500 ms`,
+			want: &Explanation{
+				Plan: &QueryPlan{
+					NodeType: "Seq Scan", Relation: "users", TotalCost: 1.01, PlanRows: 1, PlanWidth: 4,
+					ActualStartupTime: 0.010, ActualTotalTime: 0.012, ActualRows: 3, ActualLoops: 1,
+				},
+				PlanningTime:  50 * time.Microsecond,
+				ExecutionTime: 1500 * time.Microsecond,
+			},
+		},
+		{
+			name: "decimal actual rows of PostgreSQL 18",
+			raw:  `Index Scan using users_pkey on users  (cost=0.15..8.17 rows=1 width=4) (actual time=0.005..0.006 rows=0.50 loops=2)`,
+			want: &Explanation{Plan: &QueryPlan{
+				NodeType: "Index Scan", Relation: "users", StartupCost: 0.15, TotalCost: 8.17, PlanRows: 1, PlanWidth: 4,
+				ActualStartupTime: 0.005, ActualTotalTime: 0.006, ActualRows: 0.5, ActualLoops: 2,
+			}},
+		},
+		{
+			name: "analyze without timing",
+			raw:  `Seq Scan on users  (cost=0.00..1.01 rows=1 width=4) (actual rows=1.00 loops=1)`,
+			want: &Explanation{Plan: &QueryPlan{NodeType: "Seq Scan", Relation: "users", TotalCost: 1.01, PlanRows: 1, PlanWidth: 4, ActualRows: 1, ActualLoops: 1}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseTextPlan(test.raw)
+			if err != nil {
+				t.Fatalf("parseTextPlan() error = %v", err)
+			}
+			test.want.Raw = test.raw
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseTextPlan() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTextPlanErrors(t *testing.T) {
+	for _, raw := range []string{"", "Result", "Planning Time: 0.050 ms"} {
+		if _, err := parseTextPlan(raw); !errors.Is(err, ErrFailedOperation) {
+			t.Errorf("parseTextPlan(%q) error = %v, want %v", raw, err, ErrFailedOperation)
+		}
+	}
+}
+
+func TestParseJSONPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want *Explanation
+	}{
+		{
+			name: "nested nodes",
+			raw: `[{"Plan": {"Node Type": "Hash Join", "Startup Cost": 1.05, "Total Cost": 2.16, "Plan Rows": 4, "Plan Width": 68, "Plans": [
+				{"Node Type": "Seq Scan", "Relation Name": "orders", "Total Cost": 1.04, "Plan Rows": 4, "Plan Width": 36}
+			]}}]`,
+			want: &Explanation{Plan: &QueryPlan{
+				NodeType: "Hash Join", StartupCost: 1.05, TotalCost: 2.16, PlanRows: 4, PlanWidth: 68,
+				Children: []*QueryPlan{{NodeType: "Seq Scan", Relation: "orders", TotalCost: 1.04, PlanRows: 4, PlanWidth: 36}},
+			}},
+		},
+		{
+			name: "analyze with decimal actual rows",
+			raw: `[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "users", "Total Cost": 1.01, "Plan Rows": 1, "Plan Width": 4,
+				"Actual Startup Time": 0.01, "Actual Total Time": 0.012, "Actual Rows": 2.5, "Actual Loops": 2},
+				"Planning Time": 0.05, "Execution Time": 1.5}]`,
+			want: &Explanation{
+				Plan: &QueryPlan{
+					NodeType: "Seq Scan", Relation: "users", TotalCost: 1.01, PlanRows: 1, PlanWidth: 4,
+					ActualStartupTime: 0.01, ActualTotalTime: 0.012, ActualRows: 2.5, ActualLoops: 2,
+				},
+				PlanningTime:  50 * time.Microsecond,
+				ExecutionTime: 1500 * time.Microsecond,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseJSONPlan(test.raw)
+			if err != nil {
+				t.Fatalf("parseJSONPlan() error = %v", err)
+			}
+			test.want.Raw = test.raw
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseJSONPlan() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONPlanErrors(t *testing.T) {
+	for _, raw := range []string{"", "[]", `{"Plan": {}}`} {
+		if _, err := parseJSONPlan(raw); !errors.Is(err, ErrFailedOperation) {
+			t.Errorf("parseJSONPlan(%q) error = %v, want %v", raw, err, ErrFailedOperation)
+		}
+	}
+}
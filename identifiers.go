@@ -0,0 +1,123 @@
+package borm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// maxIdentifierLength is the amount of bytes PostgreSQL keeps from an identifier (NAMEDATALEN - 1)
+const maxIdentifierLength = 63
+
+// QuoteIdentifier quotes a table, column, type or role name so it can be safely used in a statement.
+func QuoteIdentifier(name string) string {
+	return pq.QuoteIdentifier(name)
+}
+
+// QuoteLiteral quotes a string so it can be safely used as a value in a statement.
+func QuoteLiteral(literal string) string {
+	return pq.QuoteLiteral(literal)
+}
+
+// quoteIdentifiers quotes each name and joins them with ", "
+func quoteIdentifiers[T ~string](names ...T) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = QuoteIdentifier(string(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteField quotes a field reference like name, alias.name or alias.*. Expressions like COUNT(id) are written as is.
+func quoteField(field string) string {
+	alias, column, aliased := strings.Cut(field, ".")
+	if !aliased {
+		if isPlainIdentifier(field) {
+			return quoteName(field)
+		}
+		return field
+	}
+	if !isPlainIdentifier(alias) {
+		return field
+	}
+	if column == "*" {
+		return quoteName(alias) + ".*"
+	}
+	if !isPlainIdentifier(column) {
+		return field
+	}
+	return quoteName(alias) + "." + quoteName(column)
+}
+
+// quoteName quotes a name written by the user folded to lower case, like PostgreSQL reads unquoted names
+func quoteName(name string) string {
+	return QuoteIdentifier(strings.ToLower(name))
+}
+
+// quoteNames quotes each name with quoteName and joins them with ", "
+func quoteNames(names ...string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteName(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteFields quotes each field reference and joins them with ", "
+func quoteFields(fields ...string) string {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = quoteField(field)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// validateIdentifier returns an error if name can't be used as a PostgreSQL identifier, even quoted.
+func validateIdentifier(kind string, name string) error {
+	if name == "" {
+		return ErrorDescription(ErrInvalidIdentifier, fmt.Sprintf("%s name must not be empty", kind))
+	}
+	if len(name) > maxIdentifierLength {
+		return ErrorDescription(ErrInvalidIdentifier, fmt.Sprintf("%s name %s is longer than %d bytes", kind, name, maxIdentifierLength))
+	}
+	if !utf8.ValidString(name) || strings.ContainsRune(name, 0) {
+		return ErrorDescription(ErrInvalidIdentifier, fmt.Sprintf("%s name %q contains invalid characters", kind, name))
+	}
+	return nil
+}
+
+// objectName builds the name PostgreSQL gives to unnamed constraints and indexes, <name>_<addition>_<label>.
+// Like makeObjectName, the longer of name and addition is shortened until the result fits in maxIdentifierLength.
+func objectName(name string, addition string, label string) string {
+	overhead := len(label) + 1
+	if addition != "" {
+		overhead++
+	}
+	nameBytes, additionBytes := len(name), len(addition)
+	for nameBytes+additionBytes > maxIdentifierLength-overhead {
+		if nameBytes > additionBytes {
+			nameBytes--
+		} else {
+			additionBytes--
+		}
+	}
+
+	parts := []string{clipIdentifier(name, nameBytes)}
+	if addition != "" {
+		parts = append(parts, clipIdentifier(addition, additionBytes))
+	}
+	return strings.Join(append(parts, label), "_")
+}
+
+// clipIdentifier keeps at most n bytes of name without splitting a character
+func clipIdentifier(name string, n int) string {
+	if n >= len(name) {
+		return name
+	}
+	for n > 0 && !utf8.RuneStart(name[n]) {
+		n--
+	}
+	return name[:n]
+}
@@ -0,0 +1,307 @@
+package borm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	INDEX_BTREE  IndexMethod = "btree"
+	INDEX_HASH   IndexMethod = "hash"
+	INDEX_GIN    IndexMethod = "gin"
+	INDEX_GIST   IndexMethod = "gist"
+	INDEX_BRIN   IndexMethod = "brin"
+	INDEX_SPGIST IndexMethod = "spgist"
+)
+
+type IndexMethod string
+
+// TableIndex is an index created after its table by the migrations.
+// Columns that are not fields of the table are expressions, like lower(email).
+type TableIndex struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	// Empty uses the default method of PostgreSQL, btree
+	Method IndexMethod
+	// Predicate of partial indexes
+	Predicate string
+	// Columns stored in the index without being part of its key
+	Included []string
+
+	table *TableRegistry
+}
+
+// FieldIndex is the structured form of the (INDEX, name) and (UNIQUE INDEX, name) tags. Fields with the same name share an index.
+type FieldIndex struct {
+	Name   string
+	Unique bool
+}
+
+// Index declares an index on columns, created by the migrations right after the table
+func (t *TableRegistry) Index(name string, columns ...string) *TableIndex {
+	return t.addIndex(name, false, columns)
+}
+func (t *TableRegistry) UniqueIndex(name string, columns ...string) *TableIndex {
+	return t.addIndex(name, true, columns)
+}
+func (t *TableRegistry) addIndex(name string, unique bool, columns []string) *TableIndex {
+	index := &TableIndex{Name: name, Columns: columns, Unique: unique, table: t}
+	t.Indexes = append(t.Indexes, index)
+
+	if err := validateIdentifier("index", name); err != nil {
+		t.Error = err
+		return index
+	}
+	if len(columns) == 0 {
+		t.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Index %s must have at least one column", name))
+		return index
+	}
+	for _, column := range columns {
+		if !t.hasColumn(column) && !strings.Contains(column, "(") {
+			t.Error = ErrorDescription(ErrNotFound, fmt.Sprintf("Index %s uses unknown column %s", name, column))
+		}
+	}
+	return index
+}
+func (i *TableIndex) Using(method IndexMethod) *TableIndex {
+	i.Method = method
+	if err := method.validate(); err != nil {
+		i.table.Error = err
+	}
+	return i
+}
+func (m IndexMethod) validate() error {
+	switch m {
+	case INDEX_BTREE, INDEX_HASH, INDEX_GIN, INDEX_GIST, INDEX_BRIN, INDEX_SPGIST:
+		return nil
+	}
+	return ErrorDescription(ErrSyntax, fmt.Sprintf("Unknown index method %q", m))
+}
+
+// Where makes the index partial, predicate is written as is
+func (i *TableIndex) Where(predicate string) *TableIndex {
+	i.Predicate = predicate
+	return i
+}
+
+// Include stores columns in the index so queries can read them without visiting the table
+func (i *TableIndex) Include(columns ...string) *TableIndex {
+	for _, column := range columns {
+		if !i.table.hasColumn(column) {
+			i.table.Error = ErrorDescription(ErrNotFound, fmt.Sprintf("Index %s includes unknown column %s", i.Name, column))
+		}
+	}
+	i.Included = append(i.Included, columns...)
+	return i
+}
+func (t *TableRegistry) hasColumn(name string) bool {
+	field, ok := t.Fields[TableFieldName(name)]
+	return ok && !field.Ignore
+}
+
+// indexDefinitions returns the indexes of the field tags sorted by name, with columns in declaration order, followed by the ones declared with Index.
+// Unnamed tag indexes are named <table>_<column>_idx, shortened to 63 bytes like PostgreSQL does.
+func (t *TableRegistry) indexDefinitions() []*TableIndex {
+	tagged := map[string]*TableIndex{}
+	for _, field := range t.columns() {
+		if field.Index == nil {
+			continue
+		}
+		name := field.Index.Name
+		if name == "" {
+			name = objectName(string(t.TableName), string(field.Name), "idx")
+		}
+		if tagged[name] == nil {
+			tagged[name] = &TableIndex{Name: name, table: t}
+		}
+		tagged[name].Columns = append(tagged[name].Columns, string(field.Name))
+		tagged[name].Unique = tagged[name].Unique || field.Index.Unique
+	}
+
+	indexes := []*TableIndex{}
+	for _, index := range tagged {
+		indexes = append(indexes, index)
+	}
+	slices.SortFunc(indexes, func(a, b *TableIndex) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return append(indexes, t.Indexes...)
+}
+func (t *TableRegistry) validateIndexes() error {
+	names := map[string]bool{}
+	for _, index := range t.indexDefinitions() {
+		if err := validateIdentifier("index", index.Name); err != nil {
+			return err
+		}
+		if names[index.Name] {
+			return ErrorDescription(ErrSyntax, fmt.Sprintf("Index %s of table %s is declared more than once", index.Name, t.TableName))
+		}
+		names[index.Name] = true
+	}
+	return nil
+}
+func parseCreateIndexQuery(index *TableIndex) *Query {
+	columns := []string{}
+	for _, column := range index.Columns {
+		if index.table.hasColumn(column) {
+			columns = append(columns, QuoteIdentifier(column))
+		} else {
+			columns = append(columns, "("+column+")")
+		}
+	}
+
+	statement := "CREATE INDEX"
+	if index.Unique {
+		statement = "CREATE UNIQUE INDEX"
+	}
+	statement += fmt.Sprintf(" %s ON %s", QuoteIdentifier(index.Name), QuoteIdentifier(string(index.table.TableName)))
+	if index.Method != "" {
+		statement += " USING " + string(index.Method)
+	}
+	statement += fmt.Sprintf(" (%s)", strings.Join(columns, ", "))
+	if len(index.Included) > 0 {
+		statement += fmt.Sprintf(" INCLUDE (%s)", quoteIdentifiers(index.Included...))
+	}
+	if index.Predicate != "" {
+		statement += " WHERE " + index.Predicate
+	}
+	return newUnsafeQuery(CREATE, statement+";")
+}
+
+// migrateIndexes creates the indexes of a table that was just created
+func (r *Commiter) migrateIndexes(ctx context.Context, t *Transaction, table *TableRegistry) error {
+	for _, index := range table.indexDefinitions() {
+		err := doMigration(ctx, t, parseCreateIndexQuery(index))
+		logMigration(ctx, "index", index.Name, "create", err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// databaseIndex is an index as introspected from pg_index
+type databaseIndex struct {
+	name      string
+	unique    bool
+	method    IndexMethod
+	columns   []string
+	included  []string
+	predicate string
+}
+
+// equal compares the declared index with the introspected one.
+// Expressions and predicates are compared without casts, quotes, parentheses and spaces, since PostgreSQL rewrites them.
+func (i *TableIndex) equal(existing databaseIndex) bool {
+	method := i.Method
+	if method == "" {
+		method = INDEX_BTREE
+	}
+	return i.Unique == existing.unique &&
+		method == existing.method &&
+		slices.EqualFunc(i.Columns, existing.columns, equalExpression) &&
+		slices.EqualFunc(i.Included, existing.included, equalExpression) &&
+		equalExpression(i.Predicate, existing.predicate)
+}
+
+var expressionCastPattern = regexp.MustCompile(`::(?:character varying|double precision|time(?:stamp)? with(?:out)? time zone|[a-z_][a-z0-9_]*)(?:\[\])?`)
+
+func equalExpression(a, b string) bool {
+	return normalizeExpression(a) == normalizeExpression(b)
+}
+func normalizeExpression(expression string) string {
+	expression = expressionCastPattern.ReplaceAllString(strings.ToLower(expression), "")
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '"', '(', ')', ' ', '\t', '\n':
+			return -1
+		}
+		return r
+	}, expression)
+}
+
+// diffIndexes compares indexes by name and definition, a changed index is dropped and created again.
+// Indexes backing constraints are left to the constraints diff.
+func diffIndexes(ctx context.Context, target doer, table *TableRegistry) ([]SchemaChange, error) {
+	existing, err := introspectIndexes(ctx, target, table.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []SchemaChange{}
+	for _, index := range table.indexDefinitions() {
+		if i := slices.IndexFunc(existing, func(e databaseIndex) bool { return e.name == index.Name }); i >= 0 {
+			equal := index.equal(existing[i])
+			existing = slices.Delete(existing, i, i+1)
+			if equal {
+				continue
+			}
+			changes = append(changes, SchemaChange{
+				Kind:   CHANGE_DROP_INDEX,
+				Table:  table.TableName,
+				Object: index.Name,
+				SQL:    fmt.Sprintf("DROP INDEX %s;", QuoteIdentifier(index.Name)),
+			})
+		}
+		changes = append(changes, SchemaChange{
+			Kind:   CHANGE_CREATE_INDEX,
+			Table:  table.TableName,
+			Object: index.Name,
+			SQL:    parseCreateIndexQuery(index).build(),
+		})
+	}
+	for _, index := range existing {
+		changes = append(changes, SchemaChange{
+			Kind:        CHANGE_DROP_INDEX,
+			Table:       table.TableName,
+			Object:      index.name,
+			SQL:         fmt.Sprintf("DROP INDEX %s;", QuoteIdentifier(index.name)),
+			Destructive: true,
+		})
+	}
+	return changes, nil
+}
+
+// introspectIndexes returns the indexes of a table that don't back a constraint.
+// pg_get_indexdef returns each key and included column, either a quoted name or an expression.
+func introspectIndexes(ctx context.Context, target doer, table TableName) ([]databaseIndex, error) {
+	indexes := []databaseIndex{}
+	query := newUnsafeQuery(SELECT, `SELECT i.relname, x.indisunique, am.amname, x.indnkeyatts,
+ARRAY(SELECT pg_catalog.pg_get_indexdef(x.indexrelid, k, true) FROM generate_series(1, x.indnatts) k ORDER BY k),
+COALESCE(pg_catalog.pg_get_expr(x.indpred, x.indrelid, true), '')
+FROM pg_catalog.pg_index x
+JOIN pg_catalog.pg_class i ON i.oid = x.indexrelid
+JOIN pg_catalog.pg_am am ON am.oid = i.relam
+JOIN pg_catalog.pg_class c ON c.oid = x.indrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND n.nspname = current_schema()
+AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint con WHERE con.conindid = x.indexrelid)
+ORDER BY i.relname`)
+	query.CurrentValues = append(query.CurrentValues, table)
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var index databaseIndex
+			var keys int
+			var columns pq.StringArray
+			if err := rows.Scan(&index.name, &index.unique, &index.method, &keys, &columns, &index.predicate); err != nil {
+				return false, err
+			}
+			index.columns = columns[:keys]
+			index.included = columns[keys:]
+			indexes = append(indexes, index)
+		}
+		return true, rows.Err()
+	})
+	if err := target.DoContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
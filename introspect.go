@@ -0,0 +1,518 @@
+package borm
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"go/format"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// DatabaseSchema describes the enums and tables of the current schema of a database
+type DatabaseSchema struct {
+	Enums  []IntrospectedEnum
+	Tables []IntrospectedTable
+}
+
+type IntrospectedEnum struct {
+	Name   TypName
+	Values []string
+}
+
+type IntrospectedTable struct {
+	Name    TableName
+	Columns []IntrospectedColumn
+	// Constraints that can't be expressed with field tags, like the ones over many columns
+	Constraints []TableConstraint
+	// Definitions of constraints borm can't declare
+	Unsupported []string
+}
+
+// IntrospectedColumn is a column in declaration order. Type is written like format_type.
+type IntrospectedColumn struct {
+	Name       TableFieldName
+	Type       string
+	NotNull    bool
+	Default    string
+	Serial     bool
+	PrimaryKey bool
+	Unique     bool
+	Reference  *FieldReference
+	Enum       bool
+}
+
+// Introspect reads the enums and tables of the current schema from pg_catalog. The versioned migrations table is skipped.
+func (m *Commiter) Introspect(ctx context.Context) (*DatabaseSchema, error) {
+	schema := &DatabaseSchema{}
+
+	enums := map[TypName]*IntrospectedEnum{}
+	enumsQuery := newUnsafeQuery(SELECT, `SELECT t.typname, e.enumlabel
+FROM pg_catalog.pg_type t
+JOIN pg_catalog.pg_enum e ON e.enumtypid = t.oid
+JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+WHERE n.nspname = current_schema()
+ORDER BY t.typname, e.enumsortorder`)
+	enumsQuery.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var name TypName
+			var value string
+			if err := rows.Scan(&name, &value); err != nil {
+				return false, err
+			}
+			if enums[name] == nil {
+				enums[name] = &IntrospectedEnum{Name: name}
+			}
+			enums[name].Values = append(enums[name].Values, value)
+		}
+		return true, rows.Err()
+	})
+	if err := m.DoContext(ctx, enumsQuery); err != nil {
+		return nil, err
+	}
+	for _, enum := range enums {
+		schema.Enums = append(schema.Enums, *enum)
+	}
+	slices.SortFunc(schema.Enums, func(a, b IntrospectedEnum) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	tables := []TableName{}
+	tablesQuery := newUnsafeQuery(SELECT, `SELECT c.relname
+FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind = 'r' AND n.nspname = current_schema() AND c.relname <> $1
+ORDER BY c.relname`)
+	tablesQuery.CurrentValues = append(tablesQuery.CurrentValues, SCHEMA_MIGRATIONS_TABLE)
+	tablesQuery.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var table TableName
+			if err := rows.Scan(&table); err != nil {
+				return false, err
+			}
+			tables = append(tables, table)
+		}
+		return true, rows.Err()
+	})
+	if err := m.DoContext(ctx, tablesQuery); err != nil {
+		return nil, err
+	}
+
+	for _, name := range tables {
+		table, err := introspectTable(ctx, m, name, enums)
+		if err != nil {
+			return nil, err
+		}
+		schema.Tables = append(schema.Tables, table)
+	}
+	return schema, nil
+}
+func introspectTable(ctx context.Context, target doer, name TableName, enums map[TypName]*IntrospectedEnum) (IntrospectedTable, error) {
+	table := IntrospectedTable{Name: name}
+	columns, err := introspectColumns(ctx, target, name)
+	if err != nil {
+		return table, err
+	}
+	constraints, err := introspectConstraints(ctx, target, name)
+	if err != nil {
+		return table, err
+	}
+
+	ordered := []databaseColumn{}
+	for _, column := range columns {
+		ordered = append(ordered, column)
+	}
+	slices.SortFunc(ordered, func(a, b databaseColumn) int {
+		return cmp.Compare(a.position, b.position)
+	})
+
+	for _, column := range ordered {
+		introspected := IntrospectedColumn{
+			Name:    column.name,
+			Type:    column.typ,
+			NotNull: column.notNull,
+			Default: column.def,
+			Serial:  strings.HasPrefix(column.def, "nextval("),
+			Enum:    enums[TypName(column.typ)] != nil,
+		}
+		if introspected.Serial {
+			introspected.Default = ""
+		}
+		table.Columns = append(table.Columns, introspected)
+	}
+
+	for _, constraint := range constraints {
+		defaultName := objectName(string(name), strings.Join(constraint.columns, "_"), map[string]string{"u": "key", "f": "fkey"}[constraint.kind])
+		if constraint.kind == "p" {
+			defaultName = objectName(string(name), "", "pkey")
+		}
+		if len(constraint.columns) != 1 || len(constraint.refColumns) > 1 || (constraint.name != defaultName && constraint.kind != "p") {
+			if tableConstraint, ok := constraint.tableConstraint(); ok {
+				table.Constraints = append(table.Constraints, tableConstraint)
+			} else {
+				table.Unsupported = append(table.Unsupported, constraint.definition())
+			}
+			continue
+		}
+		i := slices.IndexFunc(table.Columns, func(c IntrospectedColumn) bool { return string(c.Name) == constraint.columns[0] })
+		if i < 0 {
+			continue
+		}
+		switch constraint.kind {
+		case "p":
+			table.Columns[i].PrimaryKey = true
+		case "u":
+			table.Columns[i].Unique = true
+		case "f":
+			table.Columns[i].Reference = &FieldReference{
+				Table:    TableName(constraint.refTable),
+				Column:   TableFieldName(constraint.refColumns[0]),
+				OnUpdate: constraint.onUpdate,
+				OnDelete: constraint.onDelete,
+			}
+		}
+	}
+	return table, nil
+}
+
+// GoSource generates the structs, enum constants and registration code of the schema as a formatted go file.
+func (s *DatabaseSchema) GoSource(packageName string) ([]byte, error) {
+	source := &bytes.Buffer{}
+	fmt.Fprintf(source, "// Code generated by borm introspect. DO NOT EDIT.\n\npackage %s\n\n", packageName)
+
+	standard, external := "", `"github.com/Noeeekr/borm"`
+	for _, table := range s.Tables {
+		for _, column := range table.Columns {
+			goType, _ := s.goType(column)
+			if strings.Contains(goType, "time.") {
+				standard = "\"time\"\n\n"
+			}
+			if strings.HasPrefix(goType, "pq.") && !strings.Contains(external, "lib/pq") {
+				external += "\n\"github.com/lib/pq\""
+			}
+		}
+	}
+	fmt.Fprintf(source, "import (\n%s%s\n)\n\n", standard, external)
+
+	for _, enum := range s.Enums {
+		typeName := goIdentifier(string(enum.Name))
+		fmt.Fprintf(source, "type %s string\n\nconst (\n", typeName)
+		for _, value := range enum.Values {
+			fmt.Fprintf(source, "%s %s = %q\n", goConstant(string(enum.Name)+"_"+value), typeName, value)
+		}
+		source.WriteString(")\n\n")
+	}
+
+	for _, table := range s.Tables {
+		for _, unsupported := range table.Unsupported {
+			fmt.Fprintf(source, "// borm: unsupported %s\n", unsupported)
+		}
+		fmt.Fprintf(source, "type %s struct {\n", goIdentifier(string(table.Name)))
+		for _, column := range table.Columns {
+			goType, defaultType := s.goType(column)
+			fieldName := goIdentifier(string(column.Name))
+			for _, unsupported := range column.unsupported() {
+				fmt.Fprintf(source, "// borm: unsupported %s\n", unsupported)
+			}
+			if tag := column.tag(fieldName, defaultType); tag != "" {
+				fmt.Fprintf(source, "%s %s `borm:\"%s\"`\n", fieldName, goType, tag)
+			} else {
+				fmt.Fprintf(source, "%s %s\n", fieldName, goType)
+			}
+		}
+		source.WriteString("}\n\n")
+	}
+
+	s.writeRegistration(source)
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, ErrorDescription(ErrUnexpected, "Unable to format the generated code", err.Error())
+	}
+	return formatted, nil
+}
+
+// writeRegistration writes the variables and the Register function that registers every enum and table on a database
+func (s *DatabaseSchema) writeRegistration(source *bytes.Buffer) {
+	source.WriteString("var (\n")
+	for _, enum := range s.Enums {
+		fmt.Fprintf(source, "%s *borm.Enum\n", goConstant(string(enum.Name)))
+	}
+	for _, table := range s.Tables {
+		fmt.Fprintf(source, "%s *borm.TableRegistry\n", goConstant("table_"+string(table.Name)))
+	}
+	source.WriteString(")\n\n")
+
+	source.WriteString("// Register registers the introspected enums and tables on database\nfunc Register(database *borm.DatabaseRegistry) {\n")
+	for _, enum := range s.Enums {
+		values := []string{}
+		for _, value := range enum.Values {
+			values = append(values, goConstant(string(enum.Name)+"_"+value))
+		}
+		fmt.Fprintf(source, "%s = database.RegisterEnum(%q, %s)\n", goConstant(string(enum.Name)), enum.Name, strings.Join(values, ", "))
+	}
+	for _, table := range s.orderedTables() {
+		structName := goIdentifier(string(table.Name))
+		fmt.Fprintf(source, "%s = database.RegisterTable(%s{})", goConstant("table_"+string(table.Name)), structName)
+		if strings.ToLower(structName) != string(table.Name) {
+			fmt.Fprintf(source, ".Name(%q)", table.Name)
+		}
+
+		needTables, needRoles := []string{}, []string{}
+		for _, column := range table.Columns {
+			if column.Reference != nil && column.Reference.Table != table.Name {
+				needTable := goConstant("table_" + string(column.Reference.Table))
+				if !slices.Contains(needTables, needTable) {
+					needTables = append(needTables, needTable)
+				}
+			}
+			if column.Enum && !slices.Contains(needRoles, goConstant(column.Type)) {
+				needRoles = append(needRoles, goConstant(column.Type))
+			}
+		}
+		if len(needTables) > 0 {
+			fmt.Fprintf(source, ".NeedTables(%s)", strings.Join(needTables, ", "))
+		}
+		if len(needRoles) > 0 {
+			fmt.Fprintf(source, ".NeedRoles(%s)", strings.Join(needRoles, ", "))
+		}
+		for _, constraint := range table.Constraints {
+			source.WriteString(constraint.goSource())
+		}
+		source.WriteString("\n")
+	}
+	source.WriteString("}\n")
+}
+
+// orderedTables returns the tables with the tables they reference before them
+func (s *DatabaseSchema) orderedTables() []IntrospectedTable {
+	ordered := []IntrospectedTable{}
+	visited := map[TableName]bool{}
+	var visit func(table IntrospectedTable)
+	visit = func(table IntrospectedTable) {
+		if visited[table.Name] {
+			return
+		}
+		visited[table.Name] = true
+		for _, column := range table.Columns {
+			if column.Reference == nil {
+				continue
+			}
+			if i := slices.IndexFunc(s.Tables, func(t IntrospectedTable) bool { return t.Name == column.Reference.Table }); i >= 0 {
+				visit(s.Tables[i])
+			}
+		}
+		ordered = append(ordered, table)
+	}
+	for _, table := range s.Tables {
+		visit(table)
+	}
+	return ordered
+}
+
+// tableConstraint converts a constraint read from the database into the form declared on tables.
+// Returns false for exclusions with predicates. Primary keys are always named <table>_pkey.
+func (c databaseConstraint) tableConstraint() (TableConstraint, bool) {
+	constraint := TableConstraint{Name: c.name, Kind: ConstraintKind(c.kind), Columns: c.columns}
+	switch constraint.Kind {
+	case CONSTRAINT_FOREIGN_KEY:
+		constraint.Reference = &TableReference{Table: TableName(c.refTable), Columns: c.refColumns, OnUpdate: c.onUpdate, OnDelete: c.onDelete}
+	case CONSTRAINT_CHECK:
+		constraint.Columns = nil
+		expression, ok := strings.CutPrefix(strings.TrimSuffix(c.expression, " NOT VALID"), "CHECK ")
+		constraint.Expression = expression
+		return constraint, ok
+	case CONSTRAINT_EXCLUDE:
+		constraint.Columns = nil
+		method, rest, ok := strings.Cut(strings.TrimPrefix(c.expression, "EXCLUDE USING "), " ")
+		if !ok || !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") || strings.Contains(rest, "WHERE") {
+			return constraint, false
+		}
+		constraint.Method = IndexMethod(method)
+		constraint.Expression = rest[1 : len(rest)-1]
+	}
+	return constraint, true
+}
+
+// goSource returns the call that declares the constraint on its table
+func (c TableConstraint) goSource() string {
+	columns := []string{}
+	for _, column := range c.Columns {
+		columns = append(columns, fmt.Sprintf("%q", column))
+	}
+	switch c.Kind {
+	case CONSTRAINT_PRIMARY_KEY:
+		return fmt.Sprintf(".PrimaryKey(%s)", strings.Join(columns, ", "))
+	case CONSTRAINT_UNIQUE:
+		return fmt.Sprintf(".Unique(%q, %s)", c.Name, strings.Join(columns, ", "))
+	case CONSTRAINT_CHECK:
+		return fmt.Sprintf(".Check(%q, %q)", c.Name, c.Expression)
+	case CONSTRAINT_EXCLUDE:
+		return fmt.Sprintf(".Exclude(%q, %q, %q)", c.Name, c.Method, c.Expression)
+	}
+	references := []string{}
+	for _, column := range c.Reference.Columns {
+		references = append(references, fmt.Sprintf("%q", column))
+	}
+	return fmt.Sprintf(
+		".ForeignKey(%q, []string{%s}, borm.TableReference{Table: %q, Columns: []string{%s}, OnUpdate: %q, OnDelete: %q})",
+		c.Name, strings.Join(columns, ", "), c.Reference.Table, strings.Join(references, ", "), c.Reference.OnUpdate, c.Reference.OnDelete,
+	)
+}
+
+// goArrayTypes are the lib/pq types that scan arrays of a base type
+var goArrayTypes = map[string]string{
+	"text":              "pq.StringArray",
+	"character varying": "pq.StringArray",
+	"character":         "pq.StringArray",
+	"smallint":          "pq.Int64Array",
+	"integer":           "pq.Int64Array",
+	"bigint":            "pq.Int64Array",
+	"boolean":           "pq.BoolArray",
+	"real":              "pq.Float64Array",
+	"double precision":  "pq.Float64Array",
+	"numeric":           "pq.Float64Array",
+	"bytea":             "pq.ByteaArray",
+}
+
+// goType returns the go type of the column and the sql type borm infers from it.
+// Arrays of one dimension use the array types of lib/pq, other arrays are scanned as text.
+func (s *DatabaseSchema) goType(column IntrospectedColumn) (string, string) {
+	if element, ok := strings.CutSuffix(column.Type, "[]"); ok {
+		base, _, _ := strings.Cut(element, "(")
+		if goType, ok := goArrayTypes[base]; ok && !strings.HasSuffix(element, "[]") {
+			return goType, ""
+		}
+		if !column.NotNull && !column.PrimaryKey {
+			return "*string", ""
+		}
+		return "string", ""
+	}
+
+	base, _, _ := strings.Cut(column.Type, "(")
+	goType := "string"
+	switch base {
+	case "integer", "smallint":
+		goType = "int"
+	case "bigint":
+		goType = "int64"
+	case "boolean":
+		goType = "bool"
+	case "real":
+		goType = "float32"
+	case "double precision", "numeric":
+		goType = "float64"
+	case "timestamp with time zone", "timestamp without time zone", "date":
+		goType = "time.Time"
+	case "bytea":
+		goType = "[]byte"
+	}
+	if column.Enum {
+		goType = goIdentifier(column.Type)
+	}
+
+	defaultType := normalizeColumnType(parseFieldType(strings.TrimPrefix(goType, "time.")))
+	if !column.NotNull && !column.PrimaryKey && !strings.HasPrefix(goType, "[]") {
+		goType = "*" + goType
+	}
+	return goType, defaultType
+}
+
+// tag returns the borm tag of the column, omitting what borm already infers from the field
+func (c IntrospectedColumn) tag(fieldName string, defaultType string) string {
+	tags := []string{}
+	if strings.ToLower(fieldName) != string(c.Name) {
+		tags = append(tags, fmt.Sprintf("(NAME, %s)", c.Name))
+	}
+
+	switch typ := c.Type; {
+	case c.Serial && typ == "bigint":
+		tags = append(tags, "(TYPE, BIGSERIAL)")
+	case c.Serial && typ == "smallint":
+		tags = append(tags, "(TYPE, SMALLSERIAL)")
+	case c.Serial:
+		tags = append(tags, "(TYPE, SERIAL)")
+	case typ != defaultType:
+		// Tag values are separated by commas, modifiers like numeric(10,2) can't be written, see unsupported
+		if strings.Contains(typ, ",") {
+			typ, _, _ = strings.Cut(typ, "(")
+		}
+		tags = append(tags, fmt.Sprintf("(TYPE, %s)", typ))
+	}
+
+	constraints := []string{}
+	if c.PrimaryKey {
+		constraints = append(constraints, "PRIMARY KEY")
+	} else if c.NotNull && !c.Serial {
+		constraints = append(constraints, "NOT NULL")
+	}
+	if c.Unique {
+		constraints = append(constraints, "UNIQUE")
+	}
+	if c.Default != "" && c.writableDefault() {
+		constraints = append(constraints, "DEFAULT "+c.Default)
+	}
+	if len(constraints) > 0 {
+		tags = append(tags, fmt.Sprintf("(CONSTRAINTS, %s)", strings.Join(constraints, ", ")))
+	}
+
+	if reference := c.Reference; reference != nil {
+		tags = append(tags, fmt.Sprintf("(FOREIGN KEY, %s, %s)", reference.Table, reference.Column))
+		if reference.OnUpdate != "NO ACTION" {
+			tags = append(tags, fmt.Sprintf("(UPDATE, %s)", reference.OnUpdate))
+		}
+		if reference.OnDelete != "NO ACTION" {
+			tags = append(tags, fmt.Sprintf("(DELETE, %s)", reference.OnDelete))
+		}
+	}
+	return strings.ReplaceAll(strings.Join(tags, " "), `"`, `\"`)
+}
+
+// unsupported returns what the tag of the column can't write exactly
+func (c IntrospectedColumn) unsupported() []string {
+	unsupported := []string{}
+	if !c.Serial && strings.Contains(c.Type, ",") {
+		base, _, _ := strings.Cut(c.Type, "(")
+		unsupported = append(unsupported, fmt.Sprintf("type %s of %s is written as %s", c.Type, c.Name, base))
+	}
+	if c.Default != "" && !c.writableDefault() {
+		unsupported = append(unsupported, fmt.Sprintf("default %s of %s is not written", c.Default, c.Name))
+	}
+	return unsupported
+}
+
+// writableDefault reports whether the default survives the tag, which splits values on commas and lowers them
+func (c IntrospectedColumn) writableDefault() bool {
+	return !strings.Contains(c.Default, ",") && strings.ToLower(c.Default) == c.Default
+}
+
+// goIdentifier converts snake case names into exported go identifiers
+func goIdentifier(name string) string {
+	identifier := strings.Builder{}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		runes := []rune(strings.ToLower(part))
+		runes[0] = unicode.ToUpper(runes[0])
+		identifier.WriteString(string(runes))
+	}
+	return validGoIdentifier(identifier.String())
+}
+
+// goConstant converts names into upper snake case identifiers
+func goConstant(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	return validGoIdentifier(strings.ToUpper(strings.Join(parts, "_")))
+}
+func validGoIdentifier(identifier string) string {
+	if identifier == "" {
+		return "X"
+	}
+	if unicode.IsDigit([]rune(identifier)[0]) {
+		return "X" + identifier
+	}
+	return identifier
+}
@@ -0,0 +1,187 @@
+package borm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGoIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"users", "Users"},
+		{"user_accounts", "UserAccounts"},
+		{"ID", "Id"},
+		{"order-status", "OrderStatus"},
+		{"a__b", "AB"},
+		{"2fa_codes", "X2faCodes"},
+		{"héllo_wörld", "HélloWörld"},
+		{"", "X"},
+		{"_", "X"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := goIdentifier(test.name); got != test.want {
+				t.Errorf("goIdentifier(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGoConstant(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"fruits", "FRUITS"},
+		{"order_status", "ORDER_STATUS"},
+		{"fruit kinds", "FRUIT_KINDS"},
+		{"order-status", "ORDER_STATUS"},
+		{"2fa", "X2FA"},
+		{"", "X"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := goConstant(test.name); got != test.want {
+				t.Errorf("goConstant(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIntrospectedColumnTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		column      IntrospectedColumn
+		fieldName   string
+		defaultType string
+		want        string
+	}{
+		{
+			name:        "inferred column",
+			column:      IntrospectedColumn{Name: "email", Type: "text"},
+			fieldName:   "Email",
+			defaultType: "text",
+			want:        "",
+		},
+		{
+			name:        "serial primary key",
+			column:      IntrospectedColumn{Name: "id", Type: "integer", NotNull: true, Serial: true, PrimaryKey: true},
+			fieldName:   "Id",
+			defaultType: "integer",
+			want:        "(TYPE, SERIAL) (CONSTRAINTS, PRIMARY KEY)",
+		},
+		{
+			name:        "unique bigserial",
+			column:      IntrospectedColumn{Name: "code", Type: "bigint", NotNull: true, Serial: true, Unique: true},
+			fieldName:   "Code",
+			defaultType: "bigint",
+			want:        "(TYPE, BIGSERIAL) (CONSTRAINTS, UNIQUE)",
+		},
+		{
+			name:        "renamed not null",
+			column:      IntrospectedColumn{Name: "created_at", Type: "timestamp with time zone", NotNull: true},
+			fieldName:   "CreatedAt",
+			defaultType: "timestamp with time zone",
+			want:        "(NAME, created_at) (CONSTRAINTS, NOT NULL)",
+		},
+		{
+			name:        "type and default",
+			column:      IntrospectedColumn{Name: "status", Type: "character varying(20)", Default: "'new'::character varying"},
+			fieldName:   "Status",
+			defaultType: "text",
+			want:        "(TYPE, character varying(20)) (CONSTRAINTS, DEFAULT 'new'::character varying)",
+		},
+		{
+			name:        "type modifiers with commas",
+			column:      IntrospectedColumn{Name: "price", Type: "numeric(10,2)"},
+			fieldName:   "Price",
+			defaultType: "double precision",
+			want:        "(TYPE, numeric)",
+		},
+		{
+			name:        "unwritable default",
+			column:      IntrospectedColumn{Name: "label", Type: "text", Default: "'Draft'::text"},
+			fieldName:   "Label",
+			defaultType: "text",
+			want:        "",
+		},
+		{
+			name:        "quoted type",
+			column:      IntrospectedColumn{Name: "kind", Type: `"Kinds"`, NotNull: true},
+			fieldName:   "Kind",
+			defaultType: "text",
+			want:        `(TYPE, \"Kinds\") (CONSTRAINTS, NOT NULL)`,
+		},
+		{
+			name: "foreign key",
+			column: IntrospectedColumn{Name: "user_id", Type: "integer", NotNull: true, Reference: &FieldReference{
+				Table: "users", Column: "id", OnUpdate: "NO ACTION", OnDelete: "CASCADE",
+			}},
+			fieldName:   "UserId",
+			defaultType: "integer",
+			want:        "(NAME, user_id) (CONSTRAINTS, NOT NULL) (FOREIGN KEY, users, id) (DELETE, CASCADE)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.column.tag(test.fieldName, test.defaultType); got != test.want {
+				t.Errorf("tag(%q, %q) = %q, want %q", test.fieldName, test.defaultType, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDatabaseSchemaOrderedTables(t *testing.T) {
+	references := func(name TableName, tables ...TableName) IntrospectedTable {
+		table := IntrospectedTable{Name: name}
+		for _, referenced := range tables {
+			table.Columns = append(table.Columns, IntrospectedColumn{Name: TableFieldName(referenced) + "_id", Reference: &FieldReference{Table: referenced, Column: "id"}})
+		}
+		return table
+	}
+	tests := []struct {
+		name   string
+		tables []IntrospectedTable
+		want   []TableName
+	}{
+		{
+			name:   "independent tables keep their order",
+			tables: []IntrospectedTable{references("b"), references("a")},
+			want:   []TableName{"b", "a"},
+		},
+		{
+			name:   "referenced tables first",
+			tables: []IntrospectedTable{references("comments", "posts", "users"), references("posts", "users"), references("users")},
+			want:   []TableName{"users", "posts", "comments"},
+		},
+		{
+			name:   "self reference",
+			tables: []IntrospectedTable{references("categories", "categories")},
+			want:   []TableName{"categories"},
+		},
+		{
+			name:   "cycle",
+			tables: []IntrospectedTable{references("authors", "books"), references("books", "authors")},
+			want:   []TableName{"books", "authors"},
+		},
+		{
+			name:   "unknown table",
+			tables: []IntrospectedTable{references("orders", "archived")},
+			want:   []TableName{"orders"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema := &DatabaseSchema{Tables: test.tables}
+			names := []TableName{}
+			for _, table := range schema.orderedTables() {
+				names = append(names, table.Name)
+			}
+			if !slices.Equal(names, test.want) {
+				t.Errorf("orderedTables() = %v, want %v", names, test.want)
+			}
+		})
+	}
+}
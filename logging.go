@@ -0,0 +1,58 @@
+package borm
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logLevel returns the level of routine records. They are Info while debugging and Debug in production.
+// Failures are always logged as Error.
+func logLevel(err error) slog.Level {
+	if err != nil {
+		return slog.LevelError
+	}
+	if Settings().Environment().GetEnvironment() == DEBUGGING {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// logRecord writes a record to the configured logger. Does nothing if no logger is set.
+func logRecord(ctx context.Context, message string, err error, attrs ...slog.Attr) {
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logAttrs(ctx, logLevel(err), message, attrs...)
+}
+func logAttrs(ctx context.Context, level slog.Level, message string, attrs ...slog.Attr) {
+	logger := Settings().Logging().GetLogger()
+	if logger == nil {
+		return
+	}
+	logger.LogAttrs(ctx, level, message, attrs...)
+}
+func logExecution(ctx context.Context, e *Execution) {
+	logRecord(ctx, "borm query", e.Error,
+		slog.String("type", e.Type.String()),
+		slog.String("table", string(e.Table)),
+		slog.String("sql", e.SQL),
+		slog.Int("args", len(e.Args)),
+		slog.Duration("duration", e.Duration),
+		slog.Int64("rows_affected", e.RowsAffected),
+	)
+}
+
+// logMigration records an action taken on a database object, like creating a table or dropping a user
+func logMigration(ctx context.Context, object string, name string, action string, err error) {
+	logRecord(ctx, "borm migration", err,
+		slog.String("object", object),
+		slog.String("name", name),
+		slog.String("action", action),
+	)
+}
+func logConnection(ctx context.Context, host string, database DatabaseName, err error) {
+	logRecord(ctx, "borm connection", err,
+		slog.String("host", host),
+		slog.String("database", string(database)),
+	)
+}
@@ -1,33 +1,61 @@
 package borm
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 )
 
 // TransactionFactory creates, starts and commits transactions
 type TransactionFactory struct {
 	database *sql.DB
+	pipeline *ExecutionPipeline
 }
 
 func newTransactionFactory(db *sql.DB) *TransactionFactory {
 	return &TransactionFactory{
 		database: db,
+		pipeline: newExecutionPipeline(),
 	}
 }
 
+// Use registers middlewares that see every query executed by this factory and its transactions.
+func (m *TransactionFactory) Use(middlewares ...Middleware) {
+	m.pipeline.Use(middlewares...)
+}
+
 // Start starts a transaction on the manager and returns the transaction.. If another transaction is happening it returns the current transaction.
 func (m *TransactionFactory) StartTx() (*Transaction, error) {
-	tx, err := m.database.Begin()
-	if err != nil {
-		return nil, errors.Join(ErrFailedTransaction, err)
-	}
+	return m.StartTxContext(context.Background())
+}
 
-	return NewTransaction(tx), nil
+// StartTxContext starts a transaction bound to ctx. The transaction is rolled back if ctx is done before it commits.
+func (m *TransactionFactory) StartTxContext(ctx context.Context) (*Transaction, error) {
+	return m.StartTxWith(ctx, TxOptions{})
+}
+
+// InTx runs fn inside a new transaction.
+// Commits if fn returns nil. Rolls back if fn returns an error or panics, panics are propagated after the rollback.
+//
+// Operations on the transaction after it finishes return ErrTransactionDone.
+func (m *TransactionFactory) InTx(ctx context.Context, fn func(tx *Transaction) error) error {
+	return m.InTxWith(ctx, TxOptions{}, fn)
 }
 
 // No transaction
 func (m *TransactionFactory) Do(query *Query) error {
+	return m.DoContext(context.Background(), query)
+}
+
+// DoContext executes the query outside of a transaction. The query is canceled when ctx is done or its timeout expires.
+func (m *TransactionFactory) DoContext(ctx context.Context, query *Query) error {
+	return doOn(ctx, m.pipeline, m.database, query)
+}
+
+// doOn executes the query on target outside of a transaction
+func doOn(ctx context.Context, pipeline *ExecutionPipeline, target preparer, query *Query) error {
+	if query == nil {
+		return ErrorDescription(ErrSyntax, "Failed operation, cannot use empty queries")
+	}
 	if query.Error != nil {
 		return query.Error
 	}
@@ -35,29 +63,12 @@ func (m *TransactionFactory) Do(query *Query) error {
 		return err
 	}
 
-	stmt, err := m.database.Prepare(query.build())
-	if err != nil {
-		return errors.Join(ErrSyntax, err)
-	}
+	ctx, cancel := query.context(ctx)
+	defer cancel()
 
-	rows, err := stmt.Query(query.CurrentValues...)
+	execution, err := pipeline.execute(ctx, target, query)
 	if err != nil {
-		return errors.Join(ErrFailedTransaction, err)
-	}
-
-	if query.RowsScanner != nil {
-		found, err := query.Scan(rows)
-		if err != nil {
-			return err
-		}
-		// Found, Throw Error On Found
-		if found && query.throwErrorOnFound {
-			return ErrorDescription(ErrFound, "Rows found")
-		}
-		// Not Found, Default Throw Error On Not Found
-		if !found && !query.throwErrorOnFound {
-			return ErrorDescription(ErrNotFound, "No rows found")
-		}
+		return err
 	}
-	return nil
+	return query.checkFound(execution.found)
 }
@@ -1,6 +1,8 @@
 package borm
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/Noeeekr/borm/configuration"
@@ -8,15 +10,18 @@ import (
 
 // Environment migrates the environment if migrations is enabled and then attempts to connect to the database. If migrations is not enabled it jumps to the connection.
 func (m *Commiter) MigrateUsers(users ...*User) error {
+	return m.MigrateUsersContext(context.Background(), users...)
+}
+func (m *Commiter) MigrateUsersContext(ctx context.Context, users ...*User) error {
 	migrations := configuration.Settings().Migrations()
 	if !migrations.Enabled {
 		return ErrorDescription(ErrConfiguration, "Must enable migrations in settings first")
 	}
 
-	created, err := m.migrateUsers(users...)
+	created, err := m.migrateUsers(ctx, users...)
 	if err != nil {
 		if migrations.Undo {
-			return m.dropDatabaseUsers(created...)
+			return errors.Join(err, m.dropDatabaseUsers(ctx, created...))
 		}
 		return err
 	}
@@ -24,24 +29,46 @@ func (m *Commiter) MigrateUsers(users ...*User) error {
 	return nil
 }
 func (m *Commiter) MigrateDatabase(registor *DatabaseRegistry) (*Commiter, error) {
+	return m.MigrateDatabaseContext(context.Background(), registor)
+}
+func (m *Commiter) MigrateDatabaseContext(ctx context.Context, registor *DatabaseRegistry) (*Commiter, error) {
 	migrations := configuration.Settings().Migrations()
 	if !migrations.Enabled {
-		return Connect(registor)
+		return ConnectContext(ctx, registor)
 	}
 
-	err := m.migrateDatabase(registor)
+	err := m.migrateDatabase(ctx, registor)
 	if err != nil {
 		return nil, err
 	}
 
-	return Connect(registor)
+	return ConnectContext(ctx, registor)
+}
+
+// DropUsers drops the users along with every database they own
+func (m *Commiter) DropUsers(users ...*User) error {
+	return m.DropUsersContext(context.Background(), users...)
+}
+func (m *Commiter) DropUsersContext(ctx context.Context, users ...*User) error {
+	if !configuration.Settings().Migrations().Enabled {
+		return ErrorDescription(ErrConfiguration, "Must enable migrations in settings first")
+	}
+	return m.dropDatabaseUsers(ctx, users...)
+}
+func (m *Commiter) DropDatabase(registor *DatabaseRegistry) error {
+	return m.DropDatabaseContext(context.Background(), registor)
+}
+func (m *Commiter) DropDatabaseContext(ctx context.Context, registor *DatabaseRegistry) error {
+	if !configuration.Settings().Migrations().Enabled {
+		return ErrorDescription(ErrConfiguration, "Must enable migrations in settings first")
+	}
+	return m.dropDatabase(ctx, registor)
 }
 
-func (m *Commiter) migrateUsers(users ...*User) ([]*User, error) {
+func (m *Commiter) migrateUsers(ctx context.Context, users ...*User) ([]*User, error) {
 	created := []*User{}
 	for _, user := range users {
-		fmt.Println("[Creating database user]:", user.Name)
-		err := m.migrateDatabaseUser(user)
+		err := m.migrateDatabaseUser(ctx, user)
 		if err != nil {
 			return created, err
 		}
@@ -49,40 +76,44 @@ func (m *Commiter) migrateUsers(users ...*User) ([]*User, error) {
 	}
 	return created, nil
 }
-func (m *Commiter) migrateDatabaseUser(user *User) error {
-	rows, err := m.db.Query("SELECT rolname FROM pg_catalog.pg_roles WHERE rolname = $1;", user.Name)
+func (m *Commiter) migrateDatabaseUser(ctx context.Context, user *User) error {
+	if user.registerErrors != nil {
+		return user.registerErrors
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT rolname FROM pg_catalog.pg_roles WHERE rolname = $1;", user.Name)
 	if err != nil {
 		return ErrorDescription(ErrSyntax, err.Error())
 	}
 
 	configuration := configuration.Settings().Migrations()
 
-	var userExists bool
-	if rows.Next() {
-		if configuration.Ignore {
-			return nil
-		}
-		userExists = true
-	}
+	var userExists bool = rows.Next()
 	rows.Close()
+	if userExists && configuration.Ignore {
+		logMigration(ctx, "user", string(user.Name), "skip", nil)
+		return nil
+	}
 
 	if userExists && configuration.Recreate {
-
-		err := m.dropDatabaseUsers(user)
+		err := m.dropDatabaseUsers(ctx, user)
 		if err != nil {
 			return err
 		}
 	}
 
 	createUserQuery := m.parseCreateUserQuery(user)
-	_, err = m.db.Exec(createUserQuery.build())
+	err = m.execMigration(ctx, createUserQuery)
+	logMigration(ctx, "user", string(user.Name), "create", err)
 	if err != nil {
 		return ErrorDescription(ErrFailedOperation, err.Error())
 	}
 	return nil
 }
-func (m *Commiter) migrateDatabase(database *DatabaseRegistry) error {
-	rows, err := m.db.Query("SELECT datname FROM pg_catalog.pg_database WHERE datname = $1;", database.Name)
+func (m *Commiter) migrateDatabase(ctx context.Context, database *DatabaseRegistry) error {
+	if err := validateIdentifier("database", string(database.Name)); err != nil {
+		return err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT datname FROM pg_catalog.pg_database WHERE datname = $1;", database.Name)
 	if err != nil {
 		return ErrorDescription(ErrSyntax, err.Error())
 	}
@@ -90,25 +121,27 @@ func (m *Commiter) migrateDatabase(database *DatabaseRegistry) error {
 	configuration := configuration.Settings().Migrations()
 
 	var exists bool = rows.Next()
-	defer rows.Close()
+	rows.Close()
 	if exists && configuration.Ignore {
+		logMigration(ctx, "database", string(database.Name), "skip", nil)
 		return nil
 	}
 	if exists && configuration.Recreate {
-		if err := m.dropDatabase(database); err != nil {
+		if err := m.dropDatabase(ctx, database); err != nil {
 			return err
 		}
 	}
 
-	_, err = m.db.Exec(m.parseCreateDatabaseQuery(database).build())
+	err = m.execMigration(ctx, m.parseCreateDatabaseQuery(database))
+	logMigration(ctx, "database", string(database.Name), "create", err)
 	if err != nil {
 		return ErrorDescription(ErrFailedOperation, err.Error())
 	}
 	return nil
 }
-func (m *Commiter) dropDatabaseUsers(users ...*User) error {
+func (m *Commiter) dropDatabaseUsers(ctx context.Context, users ...*User) error {
 	for _, user := range users {
-		rows, err := m.db.Query("SELECT datname FROM pg_catalog.pg_database d INNER JOIN pg_catalog.pg_roles u ON d.datdba = u.oid WHERE rolname = $1;", user.Name)
+		rows, err := m.db.QueryContext(ctx, "SELECT datname FROM pg_catalog.pg_database d INNER JOIN pg_catalog.pg_roles u ON d.datdba = u.oid WHERE rolname = $1;", user.Name)
 		if err != nil {
 			return ErrorDescription(ErrFailedOperation, err.Error())
 		}
@@ -118,6 +151,7 @@ func (m *Commiter) dropDatabaseUsers(users ...*User) error {
 			var datname string
 			err := rows.Scan(&datname)
 			if err != nil {
+				rows.Close()
 				return ErrorDescription(ErrFailedOperation, err.Error())
 			}
 			datnames = append(datnames, datname)
@@ -125,33 +159,36 @@ func (m *Commiter) dropDatabaseUsers(users ...*User) error {
 		rows.Close()
 
 		for _, datname := range datnames {
-			_, err := m.db.Exec("DROP DATABASE " + datname)
+			err := m.execMigration(ctx, newUnsafeQuery(DROP, fmt.Sprintf("DROP DATABASE %s;", QuoteIdentifier(datname))))
+			logMigration(ctx, "database", datname, "drop", err)
 			if err != nil {
 				return ErrorDescription(ErrFailedOperation, err.Error())
 			}
 		}
 
 		dropUserQuery := m.parseDropUserQuery(user)
-		_, err = m.db.Exec(dropUserQuery.build())
+		err = m.execMigration(ctx, dropUserQuery)
+		logMigration(ctx, "user", string(user.Name), "drop", err)
 		if err != nil {
 			return ErrorDescription(ErrFailedOperation, err.Error())
 		}
 	}
 	return nil
 }
-func (m *Commiter) dropDatabase(database *DatabaseRegistry) error {
-	_, err := m.db.Exec(fmt.Sprintf("DROP DATABASE %s;", database.Name))
+func (m *Commiter) dropDatabase(ctx context.Context, database *DatabaseRegistry) error {
+	err := m.execMigration(ctx, newUnsafeQuery(DROP, fmt.Sprintf("DROP DATABASE %s;", QuoteIdentifier(string(database.Name)))))
+	logMigration(ctx, "database", string(database.Name), "drop", err)
 	if err != nil {
 		return ErrorDescription(ErrFailedOperation, err.Error())
 	}
 	return nil
 }
 func (m *Commiter) parseCreateDatabaseQuery(database *DatabaseRegistry) *Query {
-	return newUnsafeQuery(CREATE, fmt.Sprintf("CREATE DATABASE %s WITH OWNER = %s;", database.Name, database.Owner.Name))
+	return newUnsafeQuery(CREATE, fmt.Sprintf("CREATE DATABASE %s WITH OWNER = %s;", QuoteIdentifier(string(database.Name)), QuoteIdentifier(string(database.Owner.Name))))
 }
 func (m *Commiter) parseCreateUserQuery(user *User) *Query {
-	return newUnsafeQuery(CREATE, fmt.Sprintf("CREATE USER %s\n\tWITH LOGIN\n\tPASSWORD '%s';", user.Name, user.Password()))
+	return newUnsafeQuery(CREATE, fmt.Sprintf("CREATE USER %s\n\tWITH LOGIN\n\tPASSWORD %s;", QuoteIdentifier(string(user.Name)), QuoteLiteral(user.Password())))
 }
 func (m *Commiter) parseDropUserQuery(user *User) *Query {
-	return newUnsafeQuery(DROP, fmt.Sprintf("DROP USER %s;", user.Name))
+	return newUnsafeQuery(DROP, fmt.Sprintf("DROP USER %s;", QuoteIdentifier(string(user.Name))))
 }
@@ -0,0 +1,323 @@
+package borm
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/Noeeekr/borm/configuration"
+)
+
+// MigrationPlan holds the statements a migration would run, in order.
+// Plans of users contain their passwords, they must be stored as secrets.
+type MigrationPlan struct {
+	// Statements must run in a single transaction
+	Transactional bool               `json:"transactional"`
+	Statements    []PlannedStatement `json:"statements"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// PlannedStatement is a statement of a plan. Its arguments keep their types through JSON, so a parsed plan runs exactly like the recorded one.
+type PlannedStatement struct {
+	SQL  string `json:"sql"`
+	Args []any  `json:"args,omitempty"`
+	// Commit ends the transaction of a transactional plan after the statement, the next statements run in a new one
+	Commit bool `json:"commit,omitempty"`
+}
+
+// plannedArgument is the JSON form of an argument, tagged with its driver type
+type plannedArgument struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type planContextKey struct{}
+
+func newMigrationPlan(transactional bool) *MigrationPlan {
+	return &MigrationPlan{
+		Transactional: transactional,
+		Statements:    []PlannedStatement{},
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// PlanUsers records the statements MigrateUsers would run. Existence checks still read the database.
+func (m *Commiter) PlanUsers(ctx context.Context, users ...*User) (*MigrationPlan, error) {
+	if !configuration.Settings().Migrations().Enabled {
+		return nil, ErrorDescription(ErrConfiguration, "Must enable migrations first")
+	}
+	plan := newMigrationPlan(false)
+	if _, err := m.migrateUsers(withPlan(ctx, plan), users...); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanDropUsers records the statements DropUsers would run, including the drop of every database the users own.
+func (m *Commiter) PlanDropUsers(ctx context.Context, users ...*User) (*MigrationPlan, error) {
+	if !configuration.Settings().Migrations().Enabled {
+		return nil, ErrorDescription(ErrConfiguration, "Must enable migrations first")
+	}
+	plan := newMigrationPlan(false)
+	if err := m.dropDatabaseUsers(withPlan(ctx, plan), users...); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanDropDatabase records the statement DropDatabase would run
+func (m *Commiter) PlanDropDatabase(ctx context.Context, registor *DatabaseRegistry) (*MigrationPlan, error) {
+	if !configuration.Settings().Migrations().Enabled {
+		return nil, ErrorDescription(ErrConfiguration, "Must enable migrations first")
+	}
+	plan := newMigrationPlan(false)
+	if err := m.dropDatabase(withPlan(ctx, plan), registor); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanDatabase records the statements MigrateDatabase would run. Existence checks still read the database.
+func (m *Commiter) PlanDatabase(ctx context.Context, registor *DatabaseRegistry) (*MigrationPlan, error) {
+	if !configuration.Settings().Migrations().Enabled {
+		return nil, ErrorDescription(ErrConfiguration, "Must enable migrations first")
+	}
+	plan := newMigrationPlan(false)
+	if err := m.migrateDatabase(withPlan(ctx, plan), registor); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanRelations records the statements MigrateRelations would run, inside a read only transaction.
+func (m *Commiter) PlanRelations(ctx context.Context) (*MigrationPlan, error) {
+	if !configuration.Settings().Migrations().Enabled {
+		return nil, ErrorDescription(ErrConfiguration, "Must enable migrations first")
+	}
+	plan := newMigrationPlan(true)
+	ctx = withPlan(ctx, plan)
+
+	// Planning must not mark relations as created for the real migration
+	created := maps.Clone(m.RegistorCache)
+	defer func() { m.RegistorCache = created }()
+
+	err := m.InTxWith(ctx, TxOptions{ReadOnly: true}, func(t *Transaction) error {
+		if err := m.migrateEnumValues(ctx, t); err != nil {
+			return ErrorDescription(ErrFailedTransaction, "", err.Error())
+		}
+		plan.commit()
+		if err := m.migrateTables(ctx, t); err != nil {
+			return ErrorDescription(ErrFailedTransaction, "", err.Error())
+		}
+		for query := range m.GetMigrationQueries() {
+			plan.record(query)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ApplyPlan runs the statements of a plan. Transactional plans are rolled back if any statement fails, up to the last committed statement.
+func (m *Commiter) ApplyPlan(ctx context.Context, plan *MigrationPlan) error {
+	if !configuration.Settings().Migrations().Enabled {
+		return ErrorDescription(ErrConfiguration, "Must enable migrations first")
+	}
+	if !plan.Transactional {
+		return applyStatements(plan.Statements, func(query *Query) error {
+			return m.execMigration(ctx, query)
+		})
+	}
+	for _, statements := range plan.transactions() {
+		err := m.InTx(ctx, func(tx *Transaction) error {
+			return applyStatements(statements, func(query *Query) error {
+				return tx.DoContext(ctx, query)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transactions splits the statements after the ones that commit
+func (p *MigrationPlan) transactions() [][]PlannedStatement {
+	transactions := [][]PlannedStatement{}
+	start := 0
+	for i, statement := range p.Statements {
+		if statement.Commit || i == len(p.Statements)-1 {
+			transactions = append(transactions, p.Statements[start:i+1])
+			start = i + 1
+		}
+	}
+	return transactions
+}
+func applyStatements(statements []PlannedStatement, run func(query *Query) error) error {
+	for _, statement := range statements {
+		query := newUnsafeQuery(ALL, statement.SQL)
+		query.CurrentValues = statement.Args
+		if err := run(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SQL returns the plan as a script. Arguments of statements are written as comments.
+func (p *MigrationPlan) SQL() string {
+	script := strings.Builder{}
+	if p.Transactional {
+		script.WriteString("BEGIN;\n")
+	}
+	for i, statement := range p.Statements {
+		if len(statement.Args) > 0 {
+			arguments, _ := json.Marshal(statement.Args)
+			script.WriteString("-- args: " + string(arguments) + "\n")
+		}
+		script.WriteString(strings.TrimSuffix(strings.TrimSpace(statement.SQL), ";") + ";\n")
+		if p.Transactional && statement.Commit && i < len(p.Statements)-1 {
+			script.WriteString("COMMIT;\nBEGIN;\n")
+		}
+	}
+	if p.Transactional {
+		script.WriteString("COMMIT;\n")
+	}
+	return script.String()
+}
+func (p *MigrationPlan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+func (s PlannedStatement) MarshalJSON() ([]byte, error) {
+	arguments := make([]plannedArgument, len(s.Args))
+	for i, arg := range s.Args {
+		value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return nil, ErrorDescription(ErrInvalidType, fmt.Sprintf("Unable to store argument %d of %s", i+1, s.SQL), err.Error())
+		}
+		switch value.(type) {
+		case nil:
+			arguments[i].Type = "null"
+			continue
+		case int64:
+			arguments[i].Type = "int64"
+		case float64:
+			arguments[i].Type = "float64"
+		case bool:
+			arguments[i].Type = "bool"
+		case []byte:
+			arguments[i].Type = "bytes"
+		case string:
+			arguments[i].Type = "string"
+		case time.Time:
+			arguments[i].Type = "time"
+		}
+		if arguments[i].Value, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	type statement PlannedStatement
+	return json.Marshal(struct {
+		statement
+		Args []plannedArgument `json:"args,omitempty"`
+	}{statement: statement(s), Args: arguments})
+}
+func (s *PlannedStatement) UnmarshalJSON(data []byte) error {
+	type statement PlannedStatement
+	stored := struct {
+		*statement
+		Args []plannedArgument `json:"args,omitempty"`
+	}{statement: (*statement)(s)}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	s.Args = nil
+	for _, argument := range stored.Args {
+		value, err := argument.decode()
+		if err != nil {
+			return err
+		}
+		s.Args = append(s.Args, value)
+	}
+	return nil
+}
+func (a plannedArgument) decode() (any, error) {
+	switch a.Type {
+	case "null":
+		return nil, nil
+	case "int64":
+		return decodeArgument[int64](a.Value)
+	case "float64":
+		return decodeArgument[float64](a.Value)
+	case "bool":
+		return decodeArgument[bool](a.Value)
+	case "bytes":
+		return decodeArgument[[]byte](a.Value)
+	case "string":
+		return decodeArgument[string](a.Value)
+	case "time":
+		return decodeArgument[time.Time](a.Value)
+	}
+	return nil, fmt.Errorf("unknown argument type %q", a.Type)
+}
+func decodeArgument[T any](data json.RawMessage) (any, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// ParseMigrationPlan reads a plan serialized with JSON so it can be applied later
+func ParseMigrationPlan(data []byte) (*MigrationPlan, error) {
+	plan := &MigrationPlan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, ErrorDescription(ErrInvalidMigration, "Unable to parse the plan", err.Error())
+	}
+	return plan, nil
+}
+func (p *MigrationPlan) record(query *Query) {
+	p.Statements = append(p.Statements, PlannedStatement{SQL: query.build(), Args: query.CurrentValues})
+}
+
+// commit ends the transaction after the last recorded statement
+func (p *MigrationPlan) commit() {
+	if len(p.Statements) > 0 {
+		p.Statements[len(p.Statements)-1].Commit = true
+	}
+}
+func withPlan(ctx context.Context, plan *MigrationPlan) context.Context {
+	return context.WithValue(ctx, planContextKey{}, plan)
+}
+
+// planning returns the plan being recorded by ctx, nil when the migration must really run
+func planning(ctx context.Context) *MigrationPlan {
+	plan, _ := ctx.Value(planContextKey{}).(*MigrationPlan)
+	return plan
+}
+
+// doMigration runs a statement that changes the schema on target, or records it when planning
+func doMigration(ctx context.Context, target doer, query *Query) error {
+	if plan := planning(ctx); plan != nil {
+		plan.record(query)
+		return nil
+	}
+	return target.DoContext(ctx, query)
+}
+
+// execMigration runs a statement unprepared and outside of transactions, which is required by statements like CREATE DATABASE, or records it when planning.
+// It still goes through the middlewares.
+func (m *Commiter) execMigration(ctx context.Context, query *Query) error {
+	if plan := planning(ctx); plan != nil {
+		plan.record(query)
+		return nil
+	}
+	query.unprepared = true
+	return doOn(ctx, m.pipeline, m.db, query)
+}
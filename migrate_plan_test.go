@@ -0,0 +1,98 @@
+package borm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMigrationPlanJSON(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		plan *MigrationPlan
+		want *MigrationPlan
+	}{
+		{
+			name: "no statements",
+			plan: &MigrationPlan{Statements: []PlannedStatement{}, CreatedAt: createdAt},
+		},
+		{
+			name: "statements without arguments",
+			plan: &MigrationPlan{
+				Transactional: true,
+				Statements: []PlannedStatement{
+					{SQL: `ALTER TYPE "fruits" ADD VALUE 'cherry';`, Commit: true},
+					{SQL: `CREATE TABLE "users" ("id" serial);`},
+				},
+				CreatedAt: createdAt,
+			},
+		},
+		{
+			name: "typed arguments",
+			plan: &MigrationPlan{
+				Statements: []PlannedStatement{{
+					SQL:  "INSERT INTO t VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+					Args: []any{int64(9007199254740993), 1.5, true, []byte{0, 1, 255}, "it's", createdAt, nil, "42"},
+				}},
+				CreatedAt: createdAt,
+			},
+		},
+		{
+			name: "converted arguments",
+			plan: &MigrationPlan{
+				Statements: []PlannedStatement{{SQL: "SELECT $1, $2, $3", Args: []any{7, float32(0.5), uint8(3)}}},
+				CreatedAt:  createdAt,
+			},
+			want: &MigrationPlan{
+				Statements: []PlannedStatement{{SQL: "SELECT $1, $2, $3", Args: []any{int64(7), float64(0.5), int64(3)}}},
+				CreatedAt:  createdAt,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.plan.JSON()
+			if err != nil {
+				t.Fatalf("JSON() error = %v", err)
+			}
+			parsed, err := ParseMigrationPlan(data)
+			if err != nil {
+				t.Fatalf("ParseMigrationPlan() error = %v", err)
+			}
+			want := test.want
+			if want == nil {
+				want = test.plan
+			}
+			if !reflect.DeepEqual(parsed, want) {
+				t.Errorf("ParseMigrationPlan(%s) = %#v, want %#v", data, parsed, want)
+			}
+		})
+	}
+}
+
+func TestParseMigrationPlanErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"invalid json", `{"statements": [`},
+		{"unknown argument type", `{"statements": [{"sql": "SELECT $1", "args": [{"type": "point", "value": "(1,2)"}]}]}`},
+		{"mismatched argument value", `{"statements": [{"sql": "SELECT $1", "args": [{"type": "int64", "value": "one"}]}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseMigrationPlan([]byte(test.data)); !errors.Is(err, ErrInvalidMigration) {
+				t.Errorf("ParseMigrationPlan(%s) error = %v, want %v", test.data, err, ErrInvalidMigration)
+			}
+		})
+	}
+}
+
+func TestMigrationPlanJSONUnsupportedArgument(t *testing.T) {
+	plan := &MigrationPlan{Statements: []PlannedStatement{{SQL: "SELECT $1", Args: []any{struct{}{}}}}}
+	if _, err := plan.JSON(); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("JSON() error = %v, want %v", err, ErrInvalidType)
+	}
+}
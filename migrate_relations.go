@@ -1,8 +1,9 @@
 package borm
 
 import (
+	"context"
 	"fmt"
-	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/Noeeekr/borm/configuration"
@@ -10,86 +11,124 @@ import (
 
 // returns nil if migrations are not enabled in settings
 func (r *Commiter) MigrateRelations() error {
+	return r.MigrateRelationsContext(context.Background())
+}
+
+// MigrateRelationsContext migrates every registered type and table in a single transaction, rolled back on failure or when ctx is done.
+// With AlterExisting, renamed and added enum values are committed before, in their own transaction.
+func (r *Commiter) MigrateRelationsContext(ctx context.Context) error {
 	if !configuration.Settings().Migrations().Enabled {
 		return ErrorDescription(ErrConfiguration, "Must enable migrations first")
 	}
-	manager := newTransactionFactory(r.db)
-	t, err := manager.StartTx()
-	if err != nil {
-		return err
-	}
 
-	if err := r.migrateTables(t); err != nil {
+	err := r.InTx(ctx, func(t *Transaction) error {
+		return r.migrateEnumValues(ctx, t)
+	})
+	if err != nil {
 		return ErrorDescription(ErrFailedTransaction, "", err.Error())
 	}
-
-	for query := range r.GetMigrationQueries() {
-		if err := t.Do(query); err != nil {
+	return r.InTx(ctx, func(t *Transaction) error {
+		if err := r.migrateTables(ctx, t); err != nil {
 			return ErrorDescription(ErrFailedTransaction, "", err.Error())
 		}
-	}
-	return t.Commit()
+
+		for query := range r.GetMigrationQueries() {
+			if err := doMigration(ctx, t, query); err != nil {
+				return ErrorDescription(ErrFailedTransaction, "", err.Error())
+			}
+		}
+		return nil
+	})
 }
 func (r *Commiter) DropRelations() error {
+	return r.DropRelationsContext(context.Background())
+}
+func (r *Commiter) DropRelationsContext(ctx context.Context) error {
 	if !configuration.Settings().Migrations().Enabled {
 		return ErrorDescription(ErrConfiguration, "Must enable migrations first")
 	}
 
-	manager := newTransactionFactory(r.db)
-	t, err := manager.StartTx()
-	if err != nil {
-		return err
-	}
-
-	tables := []*TableRegistry{}
-	for _, table := range *r.DatabaseRegistry.TablesCache {
-		tables = append(tables, table)
-	}
-	if err := r.dropTables(t, tables...); err != nil {
-		return err
-	}
-
-	return t.Commit()
+	return r.InTx(ctx, func(t *Transaction) error {
+		// Tables are dropped before the tables they need
+		tables, _, err := r.orderedTables()
+		if err != nil {
+			return err
+		}
+		slices.Reverse(tables)
+		return r.dropTables(ctx, t, tables...)
+	})
 }
 func (r *Commiter) validateTables() error {
 	for _, table := range *r.DatabaseRegistry.TablesCache {
 		if table.Error != nil {
 			return table.Error
 		}
+		if err := table.validateIndexes(); err != nil {
+			return err
+		}
+		if err := table.validateConstraints(); err != nil {
+			return err
+		}
+		for _, typ := range table.RequiredTypes {
+			if enum, ok := typ.(*Enum); ok && enum.registerErrors != nil {
+				return enum.registerErrors
+			}
+		}
 	}
 	return nil
 }
-func (r *Commiter) migrateTables(t *Transaction) error {
+
+// migrateTables migrates the tables after the ones they need, then adds the foreign keys deferred by cycles
+func (r *Commiter) migrateTables(ctx context.Context, t *Transaction) error {
+	tables, deferred, err := r.orderedTables()
+	if err != nil {
+		return err
+	}
 	if err := r.validateTables(); err != nil {
 		return err
 	}
 
-	for _, table := range *r.TablesCache {
-		if subErr := r.migrateTable(t, table); subErr != nil {
+	skipped := map[TableName]bool{}
+	for _, table := range tables {
+		migrated, subErr := r.migrateTable(ctx, t, table, deferred[table.TableName])
+		if subErr != nil {
 			err := ErrorDescription(ErrSyntax, fmt.Sprintf("Unable to migrate table %s", table.TableName))
 			return ErrorJoin(err, subErr)
 		}
+		skipped[table.TableName] = !migrated
+	}
+	for _, table := range tables {
+		if skipped[table.TableName] {
+			continue
+		}
+		if subErr := r.migrateDeferredForeignKeys(ctx, t, table, deferred[table.TableName]); subErr != nil {
+			err := ErrorDescription(ErrSyntax, fmt.Sprintf("Unable to migrate the foreign keys of table %s", table.TableName))
+			return ErrorJoin(err, subErr)
+		}
 	}
 
 	return nil
 }
-func (r *Commiter) migrateTable(t *Transaction, table *TableRegistry) error {
+
+// migrateTable creates, recreates or alters a table and the types it needs. Returns false when the existing table is ignored.
+func (r *Commiter) migrateTable(ctx context.Context, t *Transaction, table *TableRegistry, deferred map[string]bool) (bool, error) {
 	var exists bool
 	existsQuery := newUnsafeQuery(SELECT, "SELECT tablename FROM pg_catalog.pg_tables WHERE tablename = $1")
 	existsQuery.Scanner(ScannerFindOne(&exists))
 
 	existsQuery.CurrentValues = append(existsQuery.CurrentValues, table.TableName)
-	err := t.Do(existsQuery)
+	err := t.DoContext(ctx, existsQuery)
 	if err != nil {
-		return err
+		return false, err
 	}
 	configuration := configuration.Settings().Migrations()
 	if exists && configuration.Ignore {
-		return nil
+		logMigration(ctx, "table", string(table.TableName), "skip", nil)
+		return false, nil
 	}
 	if exists && configuration.Recreate {
-		if err = r.dropTables(t, table); err != nil {
-			return err
+		if err = r.dropTables(ctx, t, table); err != nil {
+			return false, err
 		}
 	}
 
@@ -100,86 +139,105 @@ func (r *Commiter) migrateTable(t *Transaction, table *TableRegistry) error {
 
 		if typ.GetType() == ENUM {
 			enum := typ.(*Enum)
-			err = r.migrateEnum(t, enum)
+			err = r.migrateEnum(ctx, t, enum)
 		}
 		// error separated since the if above can become a switch with many role types
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		r.RegistorCache[string(typ.GetName())] = true
 	}
 
-	for _, subtable := range table.RequiredTables {
-		if ok := r.RegistorCache[string(subtable.TableName)]; ok {
-			continue
-		}
+	// Required tables were already migrated by migrateTables
+	r.RegistorCache[string(table.TableName)] = true
 
-		err = r.migrateTable(t, subtable)
-		if err != nil {
-			return err
+	if exists && !configuration.Recreate && configuration.Alter {
+		if err = r.alterTable(ctx, t, table, deferred); err != nil {
+			return false, err
 		}
-
-		r.RegistorCache[string(subtable.TableName)] = true
+		return true, r.migrateTimestampTrigger(ctx, t, table)
 	}
 
-	r.RegistorCache[string(table.TableName)] = true
+	query := parseCreateTableQuery(table, deferred)
+	err = doMigration(ctx, t, query)
+	logMigration(ctx, "table", string(table.TableName), "create", err)
+	if err != nil {
+		return false, err
+	}
 
-	query := parseCreateTableQuery(table)
-	return t.Do(query)
+	if err = r.migrateTimestampTrigger(ctx, t, table); err != nil {
+		return false, err
+	}
+	return true, r.migrateIndexes(ctx, t, table)
 }
-func (r *Commiter) migrateEnum(t *Transaction, enum *Enum) error {
+func (r *Commiter) migrateEnum(ctx context.Context, t *Transaction, enum *Enum) error {
 	var exists bool
-	queryStr := fmt.Sprintf("SELECT typtype FROM pg_catalog.pg_type WHERE typtype = 'e' AND typname = '%s'", enum.Name)
-	query := newUnsafeQuery(SELECT, queryStr)
+	query := newUnsafeQuery(SELECT, "SELECT typtype FROM pg_catalog.pg_type WHERE typtype = 'e' AND typname = $1")
 	query.Scanner(ScannerFindOne(&exists))
+	query.CurrentValues = append(query.CurrentValues, enum.Name)
 
-	if err := t.Do(query); err != nil {
+	if err := t.DoContext(ctx, query); err != nil {
 		return err
 	}
 
 	configuration := configuration.Settings().Migrations()
 	if exists && configuration.Ignore {
+		logMigration(ctx, "enum", string(enum.Name), "skip", nil)
 		return nil
 	}
 	if exists && configuration.Recreate {
-		err := r.dropEnum(t, enum)
+		err := r.dropEnum(ctx, t, enum)
 		if err != nil {
 			return err
 		}
 	}
-	return t.Do(parseCreateEnumQuery(enum))
+	if exists && !configuration.Recreate {
+		return r.alterEnum(ctx, t, enum)
+	}
+	err := doMigration(ctx, t, parseCreateEnumQuery(enum))
+	logMigration(ctx, "enum", string(enum.Name), "create", err)
+	return err
 }
-func (r *Commiter) dropEnum(t *Transaction, enum *Enum) error {
-	query := newUnsafeQuery(DROP, fmt.Sprintf("DROP TYPE %s CASCADE", enum.Name))
-	return t.Do(query)
+func (r *Commiter) dropEnum(ctx context.Context, t *Transaction, enum *Enum) error {
+	query := newUnsafeQuery(DROP, fmt.Sprintf("DROP TYPE %s CASCADE", QuoteIdentifier(string(enum.Name))))
+	err := doMigration(ctx, t, query)
+	logMigration(ctx, "enum", string(enum.Name), "drop", err)
+	return err
 }
-func (r *Commiter) dropTables(t *Transaction, tables ...*TableRegistry) error {
+func (r *Commiter) dropTables(ctx context.Context, t *Transaction, tables ...*TableRegistry) error {
 	for _, table := range tables {
-		query := newUnsafeQuery(DROP, fmt.Sprintf("DROP TABLE %s CASCADE", table.TableName))
-		if err := t.Do(query); err != nil {
+		query := newUnsafeQuery(DROP, fmt.Sprintf("DROP TABLE %s CASCADE", QuoteIdentifier(string(table.TableName))))
+		err := doMigration(ctx, t, query)
+		logMigration(ctx, "table", string(table.TableName), "drop", err)
+		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
-func parseCreateTableQuery(table *TableRegistry) *Query {
+
+// parseCreateTableQuery leaves out the foreign keys named in deferred
+func parseCreateTableQuery(table *TableRegistry, deferred map[string]bool) *Query {
 	var fieldStatements []string
-	for _, field := range table.Fields {
-		if field.Ignore {
-			continue
-		}
-		statement := fmt.Sprintf("\n\t%s %s", field.Name, field.Type)
+	for _, field := range table.columns() {
+		statement := fmt.Sprintf("\n\t%s %s", QuoteIdentifier(string(field.Name)), table.columnType(field))
 		if field.Constraints != "" {
 			statement += fmt.Sprintf(" %s", field.Constraints)
 		}
-		if field.ForeignKey != "" {
+		if field.ForeignKey != "" && !deferred[objectName(string(table.TableName), string(field.Name), "fkey")] {
 			statement += fmt.Sprintf(",%s", field.ForeignKey)
 		}
 		fieldStatements = append(fieldStatements, statement)
 	}
+	for _, constraint := range table.tableConstraintDefinitions() {
+		if deferred[constraint.name] {
+			continue
+		}
+		fieldStatements = append(fieldStatements, "\n\t"+constraint.definition())
+	}
 
-	queryStr := fmt.Sprintf("CREATE TABLE %s (%s\n);", table.TableName, strings.Join(fieldStatements, ","))
+	queryStr := fmt.Sprintf("CREATE TABLE %s (%s\n);", QuoteIdentifier(string(table.TableName)), strings.Join(fieldStatements, ","))
 	query := newUnsafeQuery(CREATE, queryStr)
 
 	return query
@@ -187,14 +245,10 @@ func parseCreateTableQuery(table *TableRegistry) *Query {
 func parseCreateEnumQuery(enum *Enum) *Query {
 	values := []string{}
 	for _, value := range enum.GetValues() {
-		if enum.kind == reflect.String {
-			values = append(values, fmt.Sprintf("'%s'", value))
-		} else {
-			values = append(values, fmt.Sprintf("%d", value))
-		}
+		values = append(values, QuoteLiteral(fmt.Sprint(value)))
 	}
 
-	queryStr := fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", strings.ToLower(string(enum.Name)), strings.Join(values, ", "))
+	queryStr := fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", QuoteIdentifier(string(enum.Name)), strings.Join(values, ", "))
 	query := newUnsafeQuery(CREATE, queryStr)
 	return query
 }
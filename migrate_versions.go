@@ -0,0 +1,387 @@
+package borm
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Noeeekr/borm/configuration"
+)
+
+// Table where applied versioned migrations are recorded
+const SCHEMA_MIGRATIONS_TABLE = "borm_schema_migrations"
+
+// MigrationStep changes the schema inside the transaction of its migration
+type MigrationStep func(ctx context.Context, tx *Transaction) error
+
+// Migration is a versioned schema change. Each migration runs in its own transaction along with its history record.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      MigrationStep
+	// Optional, migrations without Down can't be reverted
+	Down MigrationStep
+	// Set to the sha256 of the up script by SQL migrations. Applied migrations with a different checksum fail with ErrChecksumMismatch.
+	// Go migrations have no checksum unless one is set, so changes to their code are not detected. Set it to a version string of the code to detect them.
+	Checksum string
+}
+
+// MigrationState tells if a migration was applied
+type MigrationState struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// The applied checksum differs from the registered one
+	Modified bool
+	// Applied to the database but not registered
+	Missing bool
+}
+
+type appliedMigration struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// NewMigration creates a migration from go functions
+func NewMigration(version int64, name string, up MigrationStep, down MigrationStep) *Migration {
+	return &Migration{Version: version, Name: name, Up: up, Down: down}
+}
+
+// NewSQLMigration creates a migration from sql scripts. Scripts may contain many statements. An empty down can't be reverted.
+func NewSQLMigration(version int64, name string, up string, down string) *Migration {
+	checksum := sha256.Sum256([]byte(up))
+	migration := &Migration{
+		Version:  version,
+		Name:     name,
+		Up:       sqlStep(up),
+		Checksum: hex.EncodeToString(checksum[:]),
+	}
+	if strings.TrimSpace(down) != "" {
+		migration.Down = sqlStep(down)
+	}
+	return migration
+}
+func sqlStep(script string) MigrationStep {
+	return func(ctx context.Context, tx *Transaction) error {
+		return tx.execScript(ctx, script)
+	}
+}
+
+// RegisterMigrations adds versioned migrations to the commiter. Versions must be unique.
+func (m *Commiter) RegisterMigrations(migrations ...*Migration) error {
+	for _, migration := range migrations {
+		if migration == nil || migration.Up == nil {
+			return ErrorDescription(ErrInvalidMigration, "Migrations must have an up step")
+		}
+		if migration.Version <= 0 {
+			return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Migration %s must have a positive version", migration.Name))
+		}
+		if m.migration(migration.Version) != nil {
+			return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Version %d is registered twice", migration.Version))
+		}
+		m.versions = append(m.versions, migration)
+	}
+	slices.SortFunc(m.versions, func(a, b *Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return nil
+}
+
+// RegisterSQLMigrations registers the scripts of dir named like 0001_create_users.up.sql and 0001_create_users.down.sql.
+// Works with embed.FS.
+func (m *Commiter) RegisterSQLMigrations(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return ErrorDescription(ErrInvalidMigration, err.Error())
+	}
+
+	type scripts struct {
+		name     string
+		up, down string
+		hasUp    bool
+	}
+	found := map[int64]*scripts{}
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+		base, direction := strings.TrimSuffix(filename, ".sql"), ""
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			base, direction = strings.TrimSuffix(base, ".up"), "up"
+		case strings.HasSuffix(base, ".down"):
+			base, direction = strings.TrimSuffix(base, ".down"), "down"
+		default:
+			return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("%s must end with .up.sql or .down.sql", filename))
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("%s must start with its version", filename))
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, filename))
+		if err != nil {
+			return ErrorDescription(ErrInvalidMigration, err.Error())
+		}
+		if found[version] == nil {
+			found[version] = &scripts{name: name}
+		}
+		if direction == "up" {
+			found[version].up, found[version].hasUp = string(content), true
+		} else {
+			found[version].down = string(content)
+		}
+	}
+
+	migrations := []*Migration{}
+	for version, scripts := range found {
+		if !scripts.hasUp {
+			return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Version %d has no up script", version))
+		}
+		migrations = append(migrations, NewSQLMigration(version, scripts.name, scripts.up, scripts.down))
+	}
+	return m.RegisterMigrations(migrations...)
+}
+
+// MigrateUp applies every pending migration in version order
+func (m *Commiter) MigrateUp(ctx context.Context) error {
+	return m.withMigrationLock(ctx, func(session *migrationSession, applied map[int64]appliedMigration) error {
+		for _, migration := range m.versions {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := session.applyMigration(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the last n applied migrations
+func (m *Commiter) MigrateDown(ctx context.Context, n int) error {
+	return m.withMigrationLock(ctx, func(session *migrationSession, applied map[int64]appliedMigration) error {
+		versions := appliedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && n > 0; i, n = i-1, n-1 {
+			if err := session.revertMigration(ctx, m.migration(versions[i]), versions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateTo applies or reverts migrations until version is the last applied one. Version 0 reverts every migration.
+func (m *Commiter) MigrateTo(ctx context.Context, version int64) error {
+	if version != 0 && m.migration(version) == nil {
+		return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Version %d is not registered", version))
+	}
+	return m.withMigrationLock(ctx, func(session *migrationSession, applied map[int64]appliedMigration) error {
+		versions := appliedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && versions[i] > version; i-- {
+			if err := session.revertMigration(ctx, m.migration(versions[i]), versions[i]); err != nil {
+				return err
+			}
+		}
+		for _, migration := range m.versions {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if err := session.applyMigration(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists registered and applied migrations in version order
+func (m *Commiter) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	if err := createMigrationsTable(ctx, m); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []MigrationState{}
+	for _, migration := range m.versions {
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = record.appliedAt
+			state.Modified = record.checksum != migration.Checksum
+		}
+		states = append(states, state)
+	}
+	for _, record := range applied {
+		if m.migration(record.version) == nil {
+			states = append(states, MigrationState{
+				Version:   record.version,
+				Name:      record.name,
+				Applied:   true,
+				AppliedAt: record.appliedAt,
+				Missing:   true,
+			})
+		}
+	}
+	slices.SortFunc(states, func(a, b MigrationState) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return states, nil
+}
+
+// migrationSession runs versioned migrations on the connection holding the migrations lock, so they never wait for a second connection of the pool
+type migrationSession struct {
+	conn     *sql.Conn
+	pipeline *ExecutionPipeline
+}
+
+func (s *migrationSession) DoContext(ctx context.Context, query *Query) error {
+	return doOn(ctx, s.pipeline, s.conn, query)
+}
+func (s *migrationSession) inTx(ctx context.Context, fn func(tx *Transaction) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Join(ErrFailedTransactionStart, err)
+	}
+	t := NewTransaction(tx)
+	t.pipeline = s.pipeline
+	return runTx(ctx, t, fn)
+}
+
+// withMigrationLock serializes migrations between processes and checks the applied checksums before running fn.
+// Fails with the unlock error too if the lock can't be released.
+func (m *Commiter) withMigrationLock(ctx context.Context, fn func(session *migrationSession, applied map[int64]appliedMigration) error) (err error) {
+	if !configuration.Settings().Migrations().Enabled {
+		return ErrorDescription(ErrConfiguration, "Must enable migrations first")
+	}
+
+	lock, err := m.AdvisoryLock(ctx, LockKeyString(SCHEMA_MIGRATIONS_TABLE))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, lock.Unlock(context.WithoutCancel(ctx)))
+	}()
+	session := &migrationSession{conn: lock.conn, pipeline: m.pipeline}
+
+	if err := createMigrationsTable(ctx, session); err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, session)
+	if err != nil {
+		return err
+	}
+	for _, record := range applied {
+		migration := m.migration(record.version)
+		if migration != nil && migration.Checksum != record.checksum {
+			return ErrorDescription(ErrChecksumMismatch, fmt.Sprintf("Version %d %s was changed after being applied", record.version, record.name))
+		}
+	}
+	return fn(session, applied)
+}
+func (s *migrationSession) applyMigration(ctx context.Context, migration *Migration) error {
+	err := s.inTx(ctx, func(tx *Transaction) error {
+		if err := migration.Up(ctx, tx); err != nil {
+			return err
+		}
+		query := newUnsafeQuery(INSERT, fmt.Sprintf("INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)", QuoteIdentifier(SCHEMA_MIGRATIONS_TABLE)))
+		query.CurrentValues = []any{migration.Version, migration.Name, migration.Checksum}
+		return tx.DoContext(ctx, query)
+	})
+	logMigration(ctx, "version", migrationLabel(migration), "up", err)
+	if err != nil {
+		return ErrorJoin(ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Unable to apply version %d", migration.Version)), err)
+	}
+	return nil
+}
+
+// revertMigration runs the down step of the applied version, migration is nil if the version is not registered
+func (s *migrationSession) revertMigration(ctx context.Context, migration *Migration, version int64) error {
+	if migration == nil {
+		return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Version %d is applied but not registered", version))
+	}
+	if migration.Down == nil {
+		return ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Version %d can't be reverted", version))
+	}
+
+	err := s.inTx(ctx, func(tx *Transaction) error {
+		if err := migration.Down(ctx, tx); err != nil {
+			return err
+		}
+		query := newUnsafeQuery(DELETE, fmt.Sprintf("DELETE FROM %s WHERE version = $1", QuoteIdentifier(SCHEMA_MIGRATIONS_TABLE)))
+		query.CurrentValues = []any{version}
+		return tx.DoContext(ctx, query)
+	})
+	logMigration(ctx, "version", migrationLabel(migration), "down", err)
+	if err != nil {
+		return ErrorJoin(ErrorDescription(ErrInvalidMigration, fmt.Sprintf("Unable to revert version %d", version)), err)
+	}
+	return nil
+}
+func createMigrationsTable(ctx context.Context, target doer) error {
+	return target.DoContext(ctx, newUnsafeQuery(CREATE, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (\n\tversion BIGINT PRIMARY KEY,\n\tname TEXT NOT NULL,\n\tchecksum TEXT NOT NULL,\n\tapplied_at TIMESTAMPTZ NOT NULL DEFAULT now()\n);",
+		QuoteIdentifier(SCHEMA_MIGRATIONS_TABLE),
+	)))
+}
+func appliedMigrations(ctx context.Context, target doer) (map[int64]appliedMigration, error) {
+	applied := map[int64]appliedMigration{}
+	query := newUnsafeQuery(SELECT, fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s", QuoteIdentifier(SCHEMA_MIGRATIONS_TABLE)))
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var record appliedMigration
+			if err := rows.Scan(&record.version, &record.name, &record.checksum, &record.appliedAt); err != nil {
+				return false, err
+			}
+			applied[record.version] = record
+		}
+		return true, rows.Err()
+	})
+	if err := target.DoContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+func (m *Commiter) migration(version int64) *Migration {
+	for _, migration := range m.versions {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
+func appliedVersions(applied map[int64]appliedMigration) []int64 {
+	versions := []int64{}
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+func migrationLabel(migration *Migration) string {
+	return fmt.Sprintf("%d_%s", migration.Version, migration.Name)
+}
+
+// execScript runs sql through the middlewares without preparing it, so it may contain many statements
+func (t *Transaction) execScript(ctx context.Context, script string) error {
+	return t.DoContext(ctx, newScriptQuery(script))
+}
@@ -1,10 +1,14 @@
 package borm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ReturnScanner is used by [type Query] Scanner() method,
@@ -30,11 +34,23 @@ type Query struct {
 	Blocks []QueryBlock
 	Error  error
 
+	// Automatic timestamps not explicitly provided
+	autoFields []string
+	autoSets   map[string]autoSet
+
+	// Fields returned by a SELECT
+	selectedFields []string
+
+	// Maximum execution time, unlimited when zero
+	timeout time.Duration
+
 	// For build validation
 	*QueryValidator
 
 	RowsScanner       ReturnScanner
 	throwErrorOnFound bool
+	// Sent without being prepared, so it may contain many statements
+	unprepared bool
 }
 
 type NonOptionalQuery struct {
@@ -59,6 +75,8 @@ type ConditionalQuery struct {
 	parentQuery *Query
 	block       string
 	error       error
+	// Field compared by the condition, used to validate enum values
+	field string
 }
 
 type QueryStep int
@@ -66,6 +84,7 @@ type QueryType int
 type InternalBitwiseOperator string
 type PartialInnerJoinQuery NonOptionalQuery
 type PartialWhereQuery NonOptionalQuery
+type PartialConflictQuery NonOptionalQuery
 type AdditionalSelectQuery OptionalQuery
 type AdditionalWhereQuery OptionalQuery
 
@@ -87,6 +106,24 @@ const (
 	ALL
 )
 
+func (t QueryType) String() string {
+	switch t {
+	case SELECT:
+		return "SELECT"
+	case UPDATE:
+		return "UPDATE"
+	case DELETE:
+		return "DELETE"
+	case INSERT:
+		return "INSERT"
+	case CREATE:
+		return "CREATE"
+	case DROP:
+		return "DROP"
+	}
+	return "ALL"
+}
+
 const (
 	INTERNAL_COMPOSED_WHERE_TOKEN QueryStep = iota
 	INTERNAL_GROUP_BY_TOKEN
@@ -95,6 +132,11 @@ const (
 	INTERNAL_JOIN_TOKEN
 	INTERNAL_SET_TOKEN
 	INTERNAL_AS_TOKEN
+	INTERNAL_FROM_TOKEN
+	INTERNAL_USING_TOKEN
+	INTERNAL_VALUES_TOKEN
+	INTERNAL_SELECT_TOKEN
+	INTERNAL_CONFLICT_TOKEN
 )
 
 func newConditionalQuery(parent *Query, block string, error error) *ConditionalQuery {
@@ -108,6 +150,22 @@ func (q *Query) Scan(rows *sql.Rows) (found bool, err error) {
 	return q.RowsScanner(rows)
 }
 
+// checkFound returns the error of a scanned query depending on if rows were found
+func (q *Query) checkFound(found bool) error {
+	if q.RowsScanner == nil {
+		return nil
+	}
+	// Found, Throw Error On Found
+	if found && q.throwErrorOnFound {
+		return ErrorDescription(ErrFound, "Rows found")
+	}
+	// Not Found, Default Throw Error On Not Found
+	if !found && !q.throwErrorOnFound {
+		return ErrorDescription(ErrNotFound, "No rows found")
+	}
+	return nil
+}
+
 // Scanner expects a function that handles the rows returned by the query.
 // If no scanner is present then rows are not scanned.
 //
@@ -122,6 +180,20 @@ func (q *Query) ThrowErrorOnFound() *Query {
 	q.throwErrorOnFound = true
 	return q
 }
+
+// Timeout cancels the query if its execution takes longer than d.
+func (q *Query) Timeout(d time.Duration) *Query {
+	q.timeout = d
+	return q
+}
+
+// context returns the context the query must execute with
+func (q *Query) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if q.timeout > 0 {
+		return context.WithTimeout(ctx, q.timeout)
+	}
+	return context.WithCancel(ctx)
+}
 func (q *Query) SetError(e string) *Query {
 	q.Error = errors.New(e)
 	return q
@@ -134,6 +206,10 @@ func (q *Query) Values(values ...any) *Query {
 		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be { INSERT, UPDATE }")
 		return q
 	}
+	if q.GetQueryStep(INTERNAL_VALUES_TOKEN) || q.GetQueryStep(INTERNAL_SELECT_TOKEN) {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Values must be used once and not along with FromSelect")
+		return q
+	}
 	var valueAmount = len(values)
 	if valueAmount == 0 {
 		q.Error = ErrorDescription(ErrSyntax, "Values must not be empty. Consider removing it first or handling empty cases.")
@@ -143,27 +219,146 @@ func (q *Query) Values(values ...any) *Query {
 		q.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Invalid value amount. Wanted: multiple of %d. Recieved: %d", q.requiredValueLength, valueAmount))
 		return q
 	}
+	for i, value := range values {
+		if err := q.validateEnumValues(q.selectorFields[i%q.requiredValueLength], value); err != nil {
+			q.Error = err
+			return q
+		}
+	}
 
-	/*
-	 */
 	// Creates placeholders
+	now := time.Now()
 	valueBlock := make([]string, valueAmount/q.requiredValueLength)
 	valuesIndex := 0
 	for i := range valueBlock {
-		partialValueBlock := make([]string, q.requiredValueLength)
-		for j := range partialValueBlock {
+		partialValueBlock := make([]string, q.requiredValueLength, q.requiredValueLength+len(q.autoFields))
+		for j := range q.requiredValueLength {
 			partialValueBlock[j] = q.usePlaceholder(values[valuesIndex])
 			valuesIndex++
 		}
+		for range q.autoFields {
+			partialValueBlock = append(partialValueBlock, q.useTimestamp(now))
+		}
 		// formats to (a, b, c, ...)
 		valueBlock[i] = fmt.Sprintf("(%s)", strings.Join(partialValueBlock, ", "))
 	}
 
 	// formats to Values (a, b, c, ...) (e, f, g, ...) ...
 	valuesBlock := fmt.Sprintf("VALUES %s", strings.Join(valueBlock, ", "))
+	q.SetQueryStep(INTERNAL_VALUES_TOKEN)
 	q.appendQueryBlock(valuesBlock)
 	return q
 }
+
+// FromSelect inserts the rows returned by a SELECT query instead of Values.
+// The select query must be completely built before being used, it can't be changed afterwards.
+func (q *Query) FromSelect(selectQuery *Query) *Query {
+	if q.Error != nil {
+		return q
+	}
+	if q.Type != INSERT {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be INSERT")
+		return q
+	}
+	if q.GetQueryStep(INTERNAL_VALUES_TOKEN) || q.GetQueryStep(INTERNAL_SELECT_TOKEN) {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "FromSelect must be used once and not along with Values")
+		return q
+	}
+	if selectQuery == nil {
+		q.Error = ErrorDescription(ErrSyntax, "Failed operation, cannot use empty queries")
+		return q
+	}
+	if selectQuery.Error != nil {
+		q.Error = selectQuery.Error
+		return q
+	}
+	if selectQuery.Type != SELECT {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must use a SELECT query")
+		return q
+	}
+	if len(selectQuery.selectedFields) != q.requiredValueLength {
+		q.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Invalid column amount. Wanted: %d. Recieved: %d", q.requiredValueLength, len(selectQuery.selectedFields)))
+		return q
+	}
+	if err := selectQuery.isValid(); err != nil {
+		q.Error = err
+		return q
+	}
+
+	// Moves the select placeholders after the ones already used by this query
+	statement := shiftPlaceholders(selectQuery.build(), q.placeholderIndex-1)
+	q.CurrentValues = append(q.CurrentValues, selectQuery.CurrentValues...)
+	q.placeholderIndex += len(selectQuery.CurrentValues)
+
+	// Appends the automatic timestamps to the selected columns
+	if len(q.autoFields) > 0 {
+		now := time.Now()
+		timestamps := make([]string, len(q.autoFields))
+		for i := range q.autoFields {
+			timestamps[i] = q.useTimestamp(now)
+			if strings.HasPrefix(timestamps[i], "$") {
+				timestamps[i] += "::timestamptz"
+			}
+		}
+		statement = fmt.Sprintf("SELECT borm_source.*, %s FROM (%s) AS borm_source", strings.Join(timestamps, ", "), statement)
+	}
+
+	q.SetQueryStep(INTERNAL_SELECT_TOKEN)
+	q.appendQueryBlock(fmt.Sprintf("(%s)", statement))
+	return q
+}
+
+// OnConflict handles inserted rows that conflict on the given fields. Must be called after Values or FromSelect.
+func (q *Query) OnConflict(fields ...string) *PartialConflictQuery {
+	if q.Error != nil {
+		return newPartialConflictQuery(q)
+	}
+	if q.Type != INSERT {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be INSERT")
+		return newPartialConflictQuery(q)
+	}
+	if !q.GetQueryStep(INTERNAL_VALUES_TOKEN) && !q.GetQueryStep(INTERNAL_SELECT_TOKEN) {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be called after Values or FromSelect")
+		return newPartialConflictQuery(q)
+	}
+
+	q.registerForValidation(fields...)
+	q.SetQueryStep(INTERNAL_CONFLICT_TOKEN)
+	if len(fields) == 0 {
+		q.appendQueryBlock("ON CONFLICT")
+	} else {
+		q.appendQueryBlock(fmt.Sprintf("ON CONFLICT (%s)", quoteNames(fields...)))
+	}
+	return newPartialConflictQuery(q)
+}
+func (q *PartialConflictQuery) DoNothing() *Query {
+	if q.parentQuery.Error != nil {
+		return q.parentQuery
+	}
+	q.parentQuery.appendQueryBlock("DO NOTHING")
+	return q.parentQuery
+}
+
+// DoUpdate overwrites the given fields of the existing row with the values of the row being inserted.
+// Automatic update timestamps are also overwritten unless they are managed by a trigger.
+func (q *PartialConflictQuery) DoUpdate(fields ...string) *Query {
+	if q.parentQuery.Error != nil {
+		return q.parentQuery
+	}
+	if len(fields) == 0 {
+		q.parentQuery.Error = ErrorDescription(ErrSyntax, "DoUpdate fields must not be empty. Consider using DoNothing instead.")
+		return q.parentQuery
+	}
+	q.parentQuery.registerForValidation(fields...)
+
+	fields = append(slices.Clone(fields), missingFields(q.parentQuery.TableRegistry.timestampFields(UPDATE), fields)...)
+	sets := make([]string, len(fields))
+	for i, field := range fields {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoteName(field), quoteName(field))
+	}
+	q.parentQuery.appendQueryBlock(fmt.Sprintf("DO UPDATE SET %s", strings.Join(sets, ", ")))
+	return q.parentQuery
+}
 func (q *Query) Set(field string, value any) *Query {
 	if q.Error != nil {
 		return q
@@ -172,16 +367,30 @@ func (q *Query) Set(field string, value any) *Query {
 		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be INSERT or UPDATE")
 		return q
 	}
+	if q.GetQueryStep(INTERNAL_FROM_TOKEN) {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Set must be called before From")
+		return q
+	}
+	if err := q.validateEnumValues(field, value); err != nil {
+		q.Error = err
+		return q
+	}
 	q.selectorFields = append(q.selectorFields, field)
 
 	if q.GetQueryStep(INTERNAL_SET_TOKEN) {
+		// Explicit values are never overwritten by automatic timestamps
+		if q.overrideAutoSet(field, value) {
+			return q
+		}
 		q.appendQueryBlock(",")
-	} else {
-		q.SetQueryStep(INTERNAL_SET_TOKEN)
-		q.appendQueryBlock("SET")
+		q.appendQueryBlock(fmt.Sprintf("%s = %s", quoteName(field), q.usePlaceholder(value)))
+		return q
 	}
 
-	q.appendQueryBlock(fmt.Sprintf("%s = %s", field, q.usePlaceholder(value)))
+	q.SetQueryStep(INTERNAL_SET_TOKEN)
+	q.appendQueryBlock("SET")
+	q.appendQueryBlock(fmt.Sprintf("%s = %s", quoteName(field), q.usePlaceholder(value)))
+	q.appendAutoSets(field)
 	return q
 }
 func (q *Query) Where(conditional *ConditionalQuery) *Query {
@@ -230,6 +439,9 @@ func (p *ConditionalQuery) IsAny(fieldValues ...any) *ConditionalQuery {
 		p.error = ErrorDescription(ErrSyntax, "Where clause shouldn't be empty and can cause unwanted returns. Consider removing it if it is intended.")
 		return p
 	}
+	if p.validateEnumValues(fieldValues...) != nil {
+		return p
+	}
 
 	// formats to: A in ($1, $2, $3, ...)
 	placeholders := make([]string, fieldAmount)
@@ -271,9 +483,36 @@ func (p *ConditionalQuery) IsEqual(fieldValue any) *ConditionalQuery {
 		p.block += "IS NULL "
 		return p
 	}
+	if p.validateEnumValues(fieldValue) != nil {
+		return p
+	}
 	p.block += "= " + p.parentQuery.usePlaceholder(fieldValue)
 	return p
 }
+
+// validateEnumValues fails the condition and its query when values don't belong to the enum of the field
+func (p *ConditionalQuery) validateEnumValues(values ...any) error {
+	if p.field == "" {
+		return nil
+	}
+	if err := p.parentQuery.validateEnumValues(p.field, values...); err != nil {
+		p.error = err
+		p.parentQuery.Error = err
+		return err
+	}
+	return nil
+}
+
+// IsEqualField compares the field with another field instead of a value. Used to join tables in conditions.
+func (p *ConditionalQuery) IsEqualField(fieldName string) *ConditionalQuery {
+	if p.error != nil {
+		return p
+	}
+
+	p.parentQuery.registerForValidation(fieldName)
+	p.block += "= " + quoteField(fieldName) + " "
+	return p
+}
 func (p *ConditionalQuery) IsInRange(fieldValueA, fieldValueB any) *ConditionalQuery {
 	if p.error != nil {
 		return p
@@ -290,7 +529,7 @@ func (p *ConditionalQuery) IsLike(regex string, caseSensitive bool) *Conditional
 	if p.error != nil {
 		return p
 	}
-	likeBlock := "LIKE '" + regex + "'"
+	likeBlock := "LIKE " + QuoteLiteral(regex)
 	if !caseSensitive {
 		likeBlock = "I" + likeBlock
 	}
@@ -312,10 +551,10 @@ func (q *Query) OrderAscending(fieldName string) *Query {
 
 	q.registerForValidation(fieldName)
 	if q.GetQueryStep(INTERNAL_ORDER_TOKEN) {
-		q.appendQueryBlock(fmt.Sprintf(", %s ASC", fieldName))
+		q.appendQueryBlock(fmt.Sprintf(", %s ASC", quoteField(fieldName)))
 	} else {
 		q.SetQueryStep(INTERNAL_ORDER_TOKEN)
-		q.appendQueryBlock(fmt.Sprintf("ORDER BY %s ASC", fieldName))
+		q.appendQueryBlock(fmt.Sprintf("ORDER BY %s ASC", quoteField(fieldName)))
 	}
 
 	return q
@@ -327,10 +566,10 @@ func (q *Query) OrderDescending(fieldName string) *Query {
 
 	q.registerForValidation(fieldName)
 	if q.GetQueryStep(INTERNAL_ORDER_TOKEN) {
-		q.appendQueryBlock(fmt.Sprintf(", %s DESC", fieldName))
+		q.appendQueryBlock(fmt.Sprintf(", %s DESC", quoteField(fieldName)))
 	} else {
 		q.SetQueryStep(INTERNAL_ORDER_TOKEN)
-		q.appendQueryBlock(fmt.Sprintf("ORDER BY %s DESC", fieldName))
+		q.appendQueryBlock(fmt.Sprintf("ORDER BY %s DESC", quoteField(fieldName)))
 	}
 
 	return q
@@ -345,7 +584,7 @@ func (q *AdditionalSelectQuery) As(alias string) *Query {
 	delete(q.tableAliases, "")
 
 	q.SetQueryStep(INTERNAL_AS_TOKEN)
-	q.appendQueryBlock(fmt.Sprintf("AS %s", alias))
+	q.appendQueryBlock(fmt.Sprintf("AS %s", quoteName(alias)))
 	return q.Query
 }
 func (q *Query) RightJoin(r *TableRegistry, alias string) *PartialInnerJoinQuery {
@@ -369,14 +608,66 @@ func (q *Query) join(r *TableRegistry, joinType, alias string) *PartialInnerJoin
 	}
 	q.SetQueryStep(INTERNAL_JOIN_TOKEN)
 	q.tableAliases[alias] = r
-	q.appendQueryBlock(fmt.Sprintf("%s %s AS %s", joinType, r.TableName, alias))
+	q.appendQueryBlock(fmt.Sprintf("%s %s AS %s", joinType, QuoteIdentifier(string(r.TableName)), quoteName(alias)))
 	return newPartialInnerJoinQuery(q)
 }
+
+// From adds tables to an UPDATE so its conditions can use their fields. Must be called after Set.
+//
+// Fields of the updated table can be qualified with its table name.
+func (q *Query) From(r *TableRegistry, alias string) *Query {
+	if q.Error != nil {
+		return q
+	}
+	if q.Type != UPDATE {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be UPDATE")
+		return q
+	}
+	if !q.GetQueryStep(INTERNAL_SET_TOKEN) {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be called after Set")
+		return q
+	}
+	return q.sourceTable(r, "FROM", alias, INTERNAL_FROM_TOKEN)
+}
+
+// Using adds tables to a DELETE so its conditions can use their fields.
+//
+// Fields of the deleted table can be qualified with its table name.
+func (q *Query) Using(r *TableRegistry, alias string) *Query {
+	if q.Error != nil {
+		return q
+	}
+	if q.Type != DELETE {
+		q.Error = ErrorDescription(ErrInvalidMethodChain, "Must be DELETE")
+		return q
+	}
+	return q.sourceTable(r, "USING", alias, INTERNAL_USING_TOKEN)
+}
+func (q *Query) sourceTable(r *TableRegistry, keyword, alias string, step QueryStep) *Query {
+	if r == nil {
+		q.Error = ErrorDescription(ErrUnexpected, "Unable to use <nil> table.")
+		return q
+	}
+	if _, exists := q.tableAliases[alias]; exists {
+		q.Error = ErrorDescription(ErrSyntax, fmt.Sprintf("Alias [%s] is already in use", alias))
+		return q
+	}
+	q.tableAliases[string(q.TableRegistry.TableName)] = q.TableRegistry
+	q.tableAliases[alias] = r
+
+	if q.GetQueryStep(step) {
+		q.appendQueryBlock(fmt.Sprintf(", %s AS %s", QuoteIdentifier(string(r.TableName)), quoteName(alias)))
+	} else {
+		q.SetQueryStep(step)
+		q.appendQueryBlock(fmt.Sprintf("%s %s AS %s", keyword, QuoteIdentifier(string(r.TableName)), quoteName(alias)))
+	}
+	return q
+}
 func (q *PartialInnerJoinQuery) On(fieldA, fieldB string) *Query {
 	if q.parentQuery.Error != nil {
 		return q.parentQuery
 	}
-	q.parentQuery.appendQueryBlock(fmt.Sprintf("ON %s = %s", fieldA, fieldB))
+	q.parentQuery.appendQueryBlock(fmt.Sprintf("ON %s = %s", quoteField(fieldA), quoteField(fieldB)))
 	return q.parentQuery
 }
 func (q *Query) Returning(fields ...string) *Query {
@@ -389,7 +680,7 @@ func (q *Query) Returning(fields ...string) *Query {
 	}
 
 	q.selectorFields = append(q.selectorFields, fields...)
-	q.appendQueryBlock(fmt.Sprintf("RETURNING %s", strings.Join(fields, ", ")))
+	q.appendQueryBlock(fmt.Sprintf("RETURNING %s", quoteFields(fields...)))
 	return q
 }
 
@@ -404,7 +695,9 @@ func (q *Query) Field(fieldName string) *ConditionalQuery {
 
 	q.registerForValidation(fieldName)
 	q.SetQueryStep(INTERNAL_WHERE_TOKEN)
-	return newConditionalQuery(q, fieldName+" ", q.Error)
+	conditional := newConditionalQuery(q, quoteField(fieldName)+" ", q.Error)
+	conditional.field = fieldName
+	return conditional
 }
 func (q *Query) Offset(amount int) *Query {
 	if q.Error != nil {
@@ -433,7 +726,7 @@ func (q *Query) Limit(amount int) *Query {
 
 func (q *Query) GroupBy(fields ...string) *Query {
 	q.SetQueryStep(INTERNAL_GROUP_BY_TOKEN)
-	q.appendQueryBlock("GROUP BY " + strings.Join(fields, ", "))
+	q.appendQueryBlock("GROUP BY " + quoteFields(fields...))
 	return q
 }
 
@@ -478,14 +771,75 @@ func (q *Query) usePlaceholder(value any) string {
 	q.CurrentValues = append(q.CurrentValues, value)
 	return placeholder
 }
+
+// shiftPlaceholders adds offset to every placeholder of statement, except the ones inside string literals, quoted identifiers and dollar quoted bodies
+func shiftPlaceholders(statement string, offset int) string {
+	if offset == 0 {
+		return statement
+	}
+
+	shifted := strings.Builder{}
+	for i := 0; i < len(statement); i++ {
+		char := statement[i]
+		closing := ""
+		switch {
+		case char == '\'' || char == '"':
+			// Doubled quotes close and reopen the quoted text, which copies it the same way
+			closing = string(char)
+		case char == '$':
+			closing = dollarQuoteTag(statement[i:])
+		}
+		if closing != "" {
+			end := strings.Index(statement[i+1:], closing)
+			if end < 0 {
+				shifted.WriteString(statement[i:])
+				break
+			}
+			next := i + 1 + end + len(closing)
+			shifted.WriteString(statement[i:next])
+			i = next - 1
+			continue
+		}
+		if char != '$' {
+			shifted.WriteByte(char)
+			continue
+		}
+
+		end := i + 1
+		for end < len(statement) && statement[end] >= '0' && statement[end] <= '9' {
+			end++
+		}
+		if end == i+1 {
+			shifted.WriteByte(char)
+			continue
+		}
+		index, _ := strconv.Atoi(statement[i+1 : end])
+		shifted.WriteString(fmt.Sprintf("$%d", index+offset))
+		i = end - 1
+	}
+	return shifted.String()
+}
+
+// dollarQuoteTag returns the opening tag statement starts with, like $$ or $body$, or an empty string
+func dollarQuoteTag(statement string) string {
+	for i := 1; i < len(statement); i++ {
+		char := statement[i]
+		if char == '$' {
+			return statement[:i+1]
+		}
+		isLetter := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char == '_' || char >= 0x80
+		isDigit := char >= '0' && char <= '9'
+		if !isLetter && (i == 1 || !isDigit) {
+			return ""
+		}
+	}
+	return ""
+}
 func (q *Query) build() string {
 	blocks := make([]string, len(q.Blocks))
 	for i := range q.Blocks {
 		blocks[i] = q.Blocks[i].Block
 	}
-	if Settings().Environment().GetEnvironment() == DEBUGGING {
-		fmt.Printf("[%s]\n", strings.Join(blocks, "]\n["))
-	}
 	return strings.Join(blocks, " ")
 }
 
@@ -505,6 +859,9 @@ func (q *QueryValidator) getLastBlockType() QueryStep {
 }
 func (q *QueryValidator) isValid() error {
 	var err error
+	if q.GetQueryStep(INTERNAL_FROM_TOKEN) || q.GetQueryStep(INTERNAL_USING_TOKEN) {
+		return q.validateAllStatementSourceFields()
+	}
 	if !q.GetQueryStep(INTERNAL_AS_TOKEN) {
 		err = q.validateAllSelectStatmentUnaliasedFields()
 		if err != nil {
@@ -556,6 +913,40 @@ func (q *QueryValidator) validateAllSelectStatmentAliasedFields() error {
 	return nil
 }
 
+// validateAllStatementSourceFields validates queries using multiple tables, where fields can be aliased or belong to the query table.
+func (q *QueryValidator) validateAllStatementSourceFields() error {
+	for _, fieldStatement := range q.selectorFields {
+		fields, found := RecoverSelectStatementAliasedFields(fieldStatement)
+		if found {
+			if err := q.validateSelectStatementAliasedFields(fields, found); err != nil {
+				return err
+			}
+			continue
+		}
+
+		unaliased := FindUnaliasedFields(fieldStatement)
+		if isPlainIdentifier(fieldStatement) {
+			unaliased = append(unaliased, fieldStatement)
+		}
+		if err := q.validateTableFields("", unaliased...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func isPlainIdentifier(str string) bool {
+	if str == "" {
+		return false
+	}
+	for i, r := range str {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_'
+		isDigit := r >= '0' && r <= '9'
+		if !isLetter && (i == 0 || !isDigit) {
+			return false
+		}
+	}
+	return true
+}
 func (q *QueryValidator) validateSelectStatementAliasedFields(fields [][]string, found bool) error {
 	var alias string
 	var fieldName string
@@ -692,6 +1083,11 @@ func newPartialInnerJoinQuery(query *Query) *PartialInnerJoinQuery {
 		parentQuery: query,
 	}
 }
+func newPartialConflictQuery(query *Query) *PartialConflictQuery {
+	return &PartialConflictQuery{
+		parentQuery: query,
+	}
+}
 
 // Unsafe Queries doesn't need a table, and are not stable to use methods are may panic.
 func newUnsafeQuery(typ QueryType, str string) *Query {
@@ -702,6 +1098,13 @@ func newUnsafeQuery(typ QueryType, str string) *Query {
 	q.appendQueryBlock(str)
 	return &q
 }
+
+// newScriptQuery returns a query of one or many statements that is not prepared
+func newScriptQuery(script string) *Query {
+	q := newUnsafeQuery(ALL, script)
+	q.unprepared = true
+	return q
+}
 func NewQuery(t *TableRegistry, typ QueryType) *Query {
 	var q Query
 	if t == nil {
@@ -714,8 +1117,10 @@ func NewQuery(t *TableRegistry, typ QueryType) *Query {
 		return q.SetError(table.Error.Error())
 	}
 
+	q.Type = typ
 	q.placeholderIndex = 1
 	q.QueryValidator = newQueryValidator(t)
+	q.autoSets = make(map[string]autoSet)
 
 	return &q
 }
@@ -0,0 +1,264 @@
+package borm
+
+import (
+	"cmp"
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Amount of durations kept per statement to estimate its percentiles
+const QUERY_STATS_SAMPLES = 1024
+
+// Amount of statements kept by the statistics. The least executed one is evicted to make room for a new one.
+const QUERY_STATS_STATEMENTS = 1000
+
+// QueryStat summarizes the executions of one normalized statement
+type QueryStat struct {
+	SQL    string
+	Count  int64
+	Errors int64
+	Total  time.Duration
+	P50    time.Duration
+	P99    time.Duration
+}
+
+// QueryMonitor collects statistics of every execution of a pipeline and logs the slow ones
+type QueryMonitor struct {
+	mutex         sync.Mutex
+	slowThreshold time.Duration
+	statements    map[string]*statementStats
+}
+
+type statementStats struct {
+	count   int64
+	errors  int64
+	total   time.Duration
+	samples []time.Duration
+}
+
+func newQueryMonitor() *QueryMonitor {
+	return &QueryMonitor{
+		statements: map[string]*statementStats{},
+	}
+}
+
+// SetSlowQueryThreshold logs every execution that takes longer than threshold, with its SQL and caller location.
+// A threshold of 0 disables the slow query log.
+func (m *Commiter) SetSlowQueryThreshold(threshold time.Duration) {
+	monitor := m.pipeline.monitor
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	monitor.slowThreshold = threshold
+}
+
+// QueryStats returns the statistics of every statement executed by the commiter and its transactions, slowest total time first.
+func (m *Commiter) QueryStats() []QueryStat {
+	return m.pipeline.monitor.stats()
+}
+
+// ResetQueryStats discards the collected statistics
+func (m *Commiter) ResetQueryStats() {
+	monitor := m.pipeline.monitor
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	monitor.statements = map[string]*statementStats{}
+}
+
+// PublishQueryStats exposes QueryStats through expvar under name. Fails if name is already published.
+func (m *Commiter) PublishQueryStats(name string) error {
+	if expvar.Get(name) != nil {
+		return ErrorDescription(ErrConfiguration, fmt.Sprintf("Expvar %s is already published", name))
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		return m.QueryStats()
+	}))
+	return nil
+}
+
+// record adds an execution to the statistics and logs it if it was slow
+func (q *QueryMonitor) record(ctx context.Context, execution *Execution, err error) {
+	key := normalizeSQL(execution.SQL)
+
+	q.mutex.Lock()
+	stats, ok := q.statements[key]
+	if !ok {
+		if len(q.statements) >= QUERY_STATS_STATEMENTS {
+			q.evict()
+		}
+		stats = &statementStats{}
+		q.statements[key] = stats
+	}
+	stats.add(execution.Duration, err)
+	threshold := q.slowThreshold
+	q.mutex.Unlock()
+
+	if threshold > 0 && execution.Duration > threshold {
+		logAttrs(ctx, slog.LevelWarn, "borm slow query",
+			slog.String("sql", execution.SQL),
+			slog.Duration("duration", execution.Duration),
+			slog.Duration("threshold", threshold),
+			slog.String("caller", caller()),
+		)
+	}
+}
+
+// evict removes the least executed statement, expects the mutex to be locked
+func (q *QueryMonitor) evict() {
+	var evicted string
+	var count int64 = -1
+	for statement, stats := range q.statements {
+		if count < 0 || stats.count < count || (stats.count == count && statement < evicted) {
+			evicted, count = statement, stats.count
+		}
+	}
+	delete(q.statements, evicted)
+}
+func (q *QueryMonitor) stats() []QueryStat {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	stats := make([]QueryStat, 0, len(q.statements))
+	for statement, s := range q.statements {
+		samples := slices.Clone(s.samples)
+		slices.Sort(samples)
+		stats = append(stats, QueryStat{
+			SQL:    statement,
+			Count:  s.count,
+			Errors: s.errors,
+			Total:  s.total,
+			P50:    percentile(samples, 0.50),
+			P99:    percentile(samples, 0.99),
+		})
+	}
+	slices.SortFunc(stats, func(a, b QueryStat) int {
+		if a.Total != b.Total {
+			return cmp.Compare(b.Total, a.Total)
+		}
+		return strings.Compare(a.SQL, b.SQL)
+	})
+	return stats
+}
+
+// add keeps a uniform sample of the durations once QUERY_STATS_SAMPLES is reached
+func (s *statementStats) add(duration time.Duration, err error) {
+	s.count++
+	s.total += duration
+	if err != nil {
+		s.errors++
+	}
+	if len(s.samples) < QUERY_STATS_SAMPLES {
+		s.samples = append(s.samples, duration)
+		return
+	}
+	if i := rand.Int64N(s.count); i < QUERY_STATS_SAMPLES {
+		s.samples[i] = duration
+	}
+}
+
+// percentile expects sorted samples
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	return samples[int(p*float64(len(samples)-1))]
+}
+
+var (
+	placeholderListPattern = regexp.MustCompile(`\?(?:, \?)+`)
+	tupleListPattern       = regexp.MustCompile(`(\(\?(?:, \.\.\.)?\))(?:, \(\?(?:, \.\.\.)?\))+`)
+)
+
+// normalizeSQL collapses whitespace and replaces literals and placeholders so statements differing only by values share their statistics.
+// Lists of values are collapsed too, IN ($1, $2) and IN ($1, $2, $3) are the same statement.
+func normalizeSQL(statement string) string {
+	normalized := replaceLiterals(statement)
+	normalized = placeholderListPattern.ReplaceAllString(normalized, "?, ...")
+	return tupleListPattern.ReplaceAllString(normalized, "$1, ...")
+}
+func replaceLiterals(statement string) string {
+	var normalized strings.Builder
+	space := false
+	for i := 0; i < len(statement); i++ {
+		char := statement[i]
+		switch {
+		case unicode.IsSpace(rune(char)):
+			space = normalized.Len() > 0
+			continue
+		case space:
+			normalized.WriteByte(' ')
+			space = false
+		}
+
+		switch {
+		case char == '\'':
+			end := i + 1
+			for end < len(statement) {
+				if statement[end] == '\'' {
+					if end+1 < len(statement) && statement[end+1] == '\'' {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			normalized.WriteByte('?')
+			i = end
+		case char == '"':
+			end := strings.IndexByte(statement[i+1:], '"')
+			if end < 0 {
+				normalized.WriteString(statement[i:])
+				return normalized.String()
+			}
+			normalized.WriteString(statement[i : i+end+2])
+			i += end + 1
+		case char == '$' && i+1 < len(statement) && isDigit(statement[i+1]):
+			for i+1 < len(statement) && isDigit(statement[i+1]) {
+				i++
+			}
+			normalized.WriteByte('?')
+		case isDigit(char) && (i == 0 || !isWordByte(statement[i-1])):
+			for i+1 < len(statement) && (isDigit(statement[i+1]) || statement[i+1] == '.') {
+				i++
+			}
+			normalized.WriteByte('?')
+		default:
+			normalized.WriteByte(char)
+		}
+	}
+	return normalized.String()
+}
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+// isWordByte tells if char can precede a digit inside an identifier or placeholder
+func isWordByte(char byte) bool {
+	return char == '_' || char == '$' || char >= 0x80 || isDigit(char) || unicode.IsLetter(rune(char))
+}
+
+// caller returns the location of the first function outside borm in the stack
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/Noeeekr/borm.") && !strings.HasPrefix(frame.Function, "runtime.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package borm
+
+import "testing"
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{"whitespace", "SELECT  *\n\tFROM users ", "SELECT * FROM users"},
+		{"placeholder", "SELECT * FROM users WHERE id = $1", "SELECT * FROM users WHERE id = ?"},
+		{"string literal", "SELECT * FROM users WHERE name = 'it''s'", "SELECT * FROM users WHERE name = ?"},
+		{"numbers", "SELECT * FROM users LIMIT 10 OFFSET 2.5", "SELECT * FROM users LIMIT ? OFFSET ?"},
+		{"identifier digits", "SELECT col1 FROM t2", "SELECT col1 FROM t2"},
+		{"quoted identifier", `SELECT "col 1" FROM users`, `SELECT "col 1" FROM users`},
+		{"placeholder list", "SELECT * FROM users WHERE id IN ($1, $2, $3)", "SELECT * FROM users WHERE id IN (?, ...)"},
+		{"tuple list", "INSERT INTO users (a, b) VALUES ($1, $2), ($3, $4)", "INSERT INTO users (a, b) VALUES (?, ...), ..."},
+		{"single tuple", "INSERT INTO users (a) VALUES ($1)", "INSERT INTO users (a) VALUES (?)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := normalizeSQL(test.statement); got != test.want {
+				t.Errorf("normalizeSQL(%q) = %q, want %q", test.statement, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSQLSharesLists(t *testing.T) {
+	short := normalizeSQL("SELECT * FROM users WHERE id IN ($1, $2)")
+	long := normalizeSQL("SELECT * FROM users WHERE id IN ($1, $2, $3, $4)")
+	if short != long {
+		t.Errorf("normalizeSQL returned %q and %q for the same statement", short, long)
+	}
+}
@@ -0,0 +1,30 @@
+package borm
+
+import "testing"
+
+func TestShiftPlaceholders(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		offset    int
+		want      string
+	}{
+		{"no offset", "SELECT $1, $2", 0, "SELECT $1, $2"},
+		{"placeholders", "SELECT * FROM users WHERE id = $1 AND name = $2", 2, "SELECT * FROM users WHERE id = $3 AND name = $4"},
+		{"multiple digits", "VALUES ($9, $10)", 5, "VALUES ($14, $15)"},
+		{"string literal", "SELECT '$1', $1", 1, "SELECT '$1', $2"},
+		{"doubled quotes", "SELECT 'it''s $1', $1", 1, "SELECT 'it''s $1', $2"},
+		{"quoted identifier", `SELECT "$1", $1`, 3, `SELECT "$1", $4`},
+		{"dollar quoted body", "DO $$ SELECT $1 $$; SELECT $1", 1, "DO $$ SELECT $1 $$; SELECT $2"},
+		{"tagged dollar quoted body", "SELECT $body$ $1 $body$, $1", 1, "SELECT $body$ $1 $body$, $2"},
+		{"unterminated literal", "SELECT $1, 'abc $2", 1, "SELECT $2, 'abc $2"},
+		{"lone dollar", "SELECT $ + $1", 1, "SELECT $ + $2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := shiftPlaceholders(test.statement, test.offset); got != test.want {
+				t.Errorf("shiftPlaceholders(%q, %d) = %q, want %q", test.statement, test.offset, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package borm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Savepoint marks the current point of the transaction so its later operations can be undone with RollbackTo.
+func (t *Transaction) Savepoint(name string) error {
+	return t.execSavepoint(context.Background(), "SAVEPOINT %s", name)
+}
+
+// RollbackTo undoes every operation made after the savepoint. The savepoint can still be used afterwards.
+func (t *Transaction) RollbackTo(name string) error {
+	return t.execSavepoint(context.Background(), "ROLLBACK TO SAVEPOINT %s", name)
+}
+
+// Release forgets the savepoint, keeping the operations made after it.
+func (t *Transaction) Release(name string) error {
+	return t.execSavepoint(context.Background(), "RELEASE SAVEPOINT %s", name)
+}
+
+// InTx runs fn inside a nested transaction created from a savepoint.
+// Releases the savepoint if fn returns nil. Rolls back to the savepoint if fn returns an error or panics, keeping the outer transaction usable.
+func (t *Transaction) InTx(ctx context.Context, fn func(tx *Transaction) error) error {
+	nested, err := t.nest(ctx)
+	if err != nil {
+		return err
+	}
+	return runTx(ctx, nested, fn)
+}
+
+// nest creates a savepoint and returns a transaction bound to it
+func (t *Transaction) nest(ctx context.Context) (*Transaction, error) {
+	root := t
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.savepoints++
+
+	name := fmt.Sprintf("borm_savepoint_%d", root.savepoints)
+	if err := t.execSavepoint(ctx, "SAVEPOINT %s", name); err != nil {
+		return nil, err
+	}
+	nested := &Transaction{
+		tx:        t.tx,
+		state:     TRANSACTION_ACTIVE,
+		pipeline:  t.pipeline,
+		parent:    t,
+		savepoint: name,
+	}
+	t.nested = append(t.nested, nested)
+	return nested, nil
+}
+
+// rollbackSavepoint undoes the operations of a nested transaction and releases its savepoint
+func (t *Transaction) rollbackSavepoint(ctx context.Context) error {
+	if _, err := t.tx.ExecContext(ctx, fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", QuoteIdentifier(t.savepoint))); err != nil {
+		return ErrorDescription(ErrFailedTransactionRollback, err.Error())
+	}
+	if _, err := t.tx.ExecContext(ctx, fmt.Sprintf("RELEASE SAVEPOINT %s", QuoteIdentifier(t.savepoint))); err != nil {
+		return ErrorDescription(ErrFailedTransactionRollback, err.Error())
+	}
+	return nil
+}
+func (t *Transaction) execSavepoint(ctx context.Context, statement string, name string) error {
+	if !t.active() {
+		return ErrorDescription(ErrTransactionDone, "Unable to use a finished transaction")
+	}
+	if err := validateIdentifier("savepoint", name); err != nil {
+		return err
+	}
+	if _, err := t.tx.ExecContext(ctx, fmt.Sprintf(statement, QuoteIdentifier(name))); err != nil {
+		return ErrorDescription(ErrFailedOperation, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,602 @@
+package borm
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Changes are applied in the order of their kinds
+const (
+	CHANGE_CREATE_ENUM SchemaChangeKind = iota
+	CHANGE_RENAME_ENUM_VALUE
+	CHANGE_ADD_ENUM_VALUE
+	CHANGE_REWRITE_ENUM
+	CHANGE_CREATE_TABLE
+	CHANGE_DROP_FOREIGN_KEY
+	CHANGE_DROP_CONSTRAINT
+	CHANGE_DROP_INDEX
+	CHANGE_ADD_COLUMN
+	CHANGE_ALTER_TYPE
+	CHANGE_SET_NOT_NULL
+	CHANGE_DROP_NOT_NULL
+	CHANGE_SET_DEFAULT
+	CHANGE_DROP_DEFAULT
+	CHANGE_DROP_COLUMN
+	CHANGE_ADD_CONSTRAINT
+	CHANGE_CREATE_INDEX
+	CHANGE_ADD_FOREIGN_KEY
+)
+
+type SchemaChangeKind int
+
+func (k SchemaChangeKind) String() string {
+	return [...]string{
+		"create enum", "rename enum value", "add enum value", "rewrite enum", "create table", "drop foreign key", "drop constraint", "drop index", "add column", "alter type", "set not null",
+		"drop not null", "set default", "drop default", "drop column", "add constraint", "create index", "add foreign key",
+	}[k]
+}
+
+// SchemaChange is a single statement of a schema plan.
+// Destructive changes may lose data, like dropping columns and constraints or converting column types, or fail on tables with rows, like adding NOT NULL columns without a default.
+type SchemaChange struct {
+	Kind        SchemaChangeKind
+	Table       TableName
+	Object      string
+	SQL         string
+	Destructive bool
+}
+
+// enumValue reports whether the change renames or adds a value of an existing enum
+func (c SchemaChange) enumValue() bool {
+	return c.Kind == CHANGE_RENAME_ENUM_VALUE || c.Kind == CHANGE_ADD_ENUM_VALUE
+}
+
+// SchemaPlan holds the ordered changes that make the database match the registry
+type SchemaPlan struct {
+	Changes []SchemaChange
+}
+
+// doer runs queries on a commiter or a transaction
+type doer interface {
+	DoContext(ctx context.Context, query *Query) error
+}
+
+// columnDefinition is the structured form of a column, used to compare the registry with the database
+type columnDefinition struct {
+	name       TableFieldName
+	typ        string
+	notNull    bool
+	unique     bool
+	primaryKey bool
+	// Normalized, empty when there is no default
+	def       string
+	serial    bool
+	reference *FieldReference
+}
+
+type databaseConstraint struct {
+	name       string
+	kind       string
+	columns    []string
+	refTable   string
+	refColumns []string
+	onUpdate   string
+	onDelete   string
+	// Clause of CHECK and EXCLUDE constraints, written like pg_get_constraintdef
+	expression string
+	deferrable bool
+}
+
+// Safe returns the changes that don't lose data
+func (p *SchemaPlan) Safe() []SchemaChange {
+	return slices.DeleteFunc(slices.Clone(p.Changes), func(c SchemaChange) bool { return c.Destructive })
+}
+
+// Destructive returns the changes that may lose data
+func (p *SchemaPlan) Destructive() []SchemaChange {
+	return slices.DeleteFunc(slices.Clone(p.Changes), func(c SchemaChange) bool { return !c.Destructive })
+}
+
+// SQL returns the statements of the plan. Destructive ones are commented out, so running the output only applies the safe changes.
+func (p *SchemaPlan) SQL() string {
+	statements := []string{}
+	for _, change := range p.Changes {
+		if !change.Destructive {
+			statements = append(statements, change.SQL)
+			continue
+		}
+		statements = append(statements, "-- destructive "+change.Kind.String())
+		for _, line := range strings.Split(change.SQL, "\n") {
+			statements = append(statements, "-- "+line)
+		}
+	}
+	return strings.Join(statements, "\n")
+}
+
+// DiffSchema compares every registered table with the database and returns the changes needed to match them.
+func (m *Commiter) DiffSchema(ctx context.Context) (*SchemaPlan, error) {
+	tables, deferred, err := m.orderedTables()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validateTables(); err != nil {
+		return nil, err
+	}
+
+	plan := &SchemaPlan{}
+	enums := map[TypName]bool{}
+	for _, table := range tables {
+		for _, typ := range table.RequiredTypes {
+			enum, ok := typ.(*Enum)
+			if !ok || enums[enum.Name] {
+				continue
+			}
+			enums[enum.Name] = true
+
+			changes, err := diffEnum(ctx, m, enum)
+			if err != nil {
+				return nil, err
+			}
+			plan.Changes = append(plan.Changes, changes...)
+		}
+
+		changes, err := diffTable(ctx, m, table, deferred[table.TableName])
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+
+	slices.SortStableFunc(plan.Changes, func(a, b SchemaChange) int {
+		return cmp.Compare(a.Kind, b.Kind)
+	})
+	return plan, nil
+}
+
+// ApplySchemaPlan runs the plan in a single transaction. Destructive changes are skipped unless destructive is true.
+// Renamed and added enum values are committed first in their own transaction, PostgreSQL doesn't allow using a value in the transaction that adds it.
+func (m *Commiter) ApplySchemaPlan(ctx context.Context, plan *SchemaPlan, destructive bool) error {
+	values := slices.DeleteFunc(slices.Clone(plan.Changes), func(c SchemaChange) bool { return !c.enumValue() })
+	if len(values) > 0 {
+		err := m.InTx(ctx, func(tx *Transaction) error {
+			return applySchemaChanges(ctx, tx, values, destructive)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return m.InTx(ctx, func(tx *Transaction) error {
+		return applySchemaChanges(ctx, tx, slices.DeleteFunc(slices.Clone(plan.Changes), SchemaChange.enumValue), destructive)
+	})
+}
+func applySchemaChanges(ctx context.Context, target doer, changes []SchemaChange, destructive bool) error {
+	for _, change := range changes {
+		name := strings.Trim(fmt.Sprintf("%s.%s", change.Table, change.Object), ".")
+		if change.Destructive && !destructive {
+			logMigration(ctx, "schema", name, "skip "+change.Kind.String(), nil)
+			continue
+		}
+		err := doMigration(ctx, target, newUnsafeQuery(ALL, change.SQL))
+		logMigration(ctx, "schema", name, change.Kind.String(), err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffTable returns the changes of a single table
+func diffTable(ctx context.Context, target doer, table *TableRegistry, deferred map[string]bool) ([]SchemaChange, error) {
+	columns, err := introspectColumns(ctx, target, table.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		changes := []SchemaChange{{
+			Kind:  CHANGE_CREATE_TABLE,
+			Table: table.TableName,
+			SQL:   parseCreateTableQuery(table, deferred).build(),
+		}}
+		if Settings().Timestamps().GetSource() == DATABASE_TRIGGER {
+			for _, query := range parseTimestampTriggerQueries(table) {
+				changes = append(changes, SchemaChange{Kind: CHANGE_CREATE_TABLE, Table: table.TableName, Object: "trigger", SQL: query.build()})
+			}
+		}
+		for _, index := range table.indexDefinitions() {
+			changes = append(changes, SchemaChange{Kind: CHANGE_CREATE_INDEX, Table: table.TableName, Object: index.Name, SQL: parseCreateIndexQuery(index).build()})
+		}
+		for _, constraint := range table.deferredConstraints(deferred) {
+			changes = append(changes, SchemaChange{
+				Kind:   CHANGE_ADD_FOREIGN_KEY,
+				Table:  table.TableName,
+				Object: constraint.name,
+				SQL:    fmt.Sprintf("ALTER TABLE %s ADD %s;", QuoteIdentifier(string(table.TableName)), constraint.definition()),
+			})
+		}
+		return changes, nil
+	}
+	constraints, err := introspectConstraints(ctx, target, table.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	tableName := QuoteIdentifier(string(table.TableName))
+	alter := func(kind SchemaChangeKind, column string, destructive bool, statement string, args ...any) SchemaChange {
+		return SchemaChange{
+			Kind:        kind,
+			Table:       table.TableName,
+			Object:      column,
+			SQL:         fmt.Sprintf("ALTER TABLE %s "+statement+";", append([]any{tableName}, args...)...),
+			Destructive: destructive,
+		}
+	}
+
+	changes := []SchemaChange{}
+	declared := map[string]bool{}
+	for _, definition := range table.columnDefinitions() {
+		name := string(definition.name)
+		column := QuoteIdentifier(name)
+		declared[name] = true
+
+		existing, ok := columns[name]
+		if !ok {
+			statement := fmt.Sprintf("ADD COLUMN %s %s", column, table.columnType(table.Fields[definition.name]))
+			if definition.def != "" {
+				statement += " DEFAULT " + definition.def
+			}
+			if definition.notNull && !definition.serial {
+				statement += " NOT NULL"
+			}
+			// Existing rows can't be filled without a default
+			failsWithRows := definition.notNull && !definition.serial && definition.def == ""
+			changes = append(changes, alter(CHANGE_ADD_COLUMN, name, failsWithRows, statement))
+		} else {
+			if existing.typ != definition.typ {
+				columnType := table.columnType(table.Fields[definition.name])
+				changes = append(changes, alter(CHANGE_ALTER_TYPE, name, true, "ALTER COLUMN %s TYPE %s USING %s::%s", column, columnType, column, columnType))
+			}
+			if definition.notNull && !existing.notNull {
+				// Fails if a row has NULL
+				changes = append(changes, alter(CHANGE_SET_NOT_NULL, name, true, "ALTER COLUMN %s SET NOT NULL", column))
+			}
+			if !definition.notNull && existing.notNull {
+				changes = append(changes, alter(CHANGE_DROP_NOT_NULL, name, false, "ALTER COLUMN %s DROP NOT NULL", column))
+			}
+			if !definition.serial && definition.def != existing.def {
+				if definition.def == "" {
+					changes = append(changes, alter(CHANGE_DROP_DEFAULT, name, false, "ALTER COLUMN %s DROP DEFAULT", column))
+				} else {
+					changes = append(changes, alter(CHANGE_SET_DEFAULT, name, false, "ALTER COLUMN %s SET DEFAULT %s", column, definition.def))
+				}
+			}
+		}
+	}
+	existingColumns := slices.Collect(maps.Values(columns))
+	slices.SortFunc(existingColumns, func(a, b databaseColumn) int {
+		return cmp.Compare(a.position, b.position)
+	})
+	for _, existing := range existingColumns {
+		if !declared[string(existing.name)] {
+			changes = append(changes, alter(CHANGE_DROP_COLUMN, string(existing.name), true, "DROP COLUMN %s", QuoteIdentifier(string(existing.name))))
+		}
+	}
+
+	// Constraints missing from the registry may have been added by hand or by versioned migrations, dropping them is destructive
+	wanted := table.constraintDefinitions(deferred)
+	dropped := map[string]bool{}
+	for _, constraint := range constraints {
+		if i := slices.IndexFunc(wanted, constraint.equal); i >= 0 {
+			wanted = slices.Delete(wanted, i, i+1)
+			continue
+		}
+		kind := CHANGE_DROP_CONSTRAINT
+		if constraint.kind == "f" {
+			kind = CHANGE_DROP_FOREIGN_KEY
+		}
+		dropped[constraint.name] = true
+		changes = append(changes, alter(kind, constraint.name, true, "DROP CONSTRAINT %s", QuoteIdentifier(constraint.name)))
+	}
+	// Primary keys and unique constraints must exist before the foreign keys that reference them.
+	// A changed constraint keeps its name, it can only be added again once the destructive drop is applied.
+	for _, constraint := range wanted {
+		kind := CHANGE_ADD_CONSTRAINT
+		if constraint.kind == "f" {
+			kind = CHANGE_ADD_FOREIGN_KEY
+		}
+		changes = append(changes, alter(kind, constraint.name, dropped[constraint.name], "ADD %s", constraint.definition()))
+	}
+
+	indexes, err := diffIndexes(ctx, target, table)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, indexes...)
+
+	// Columns keep their declaration order inside each kind
+	slices.SortStableFunc(changes, func(a, b SchemaChange) int {
+		return cmp.Compare(a.Kind, b.Kind)
+	})
+	return changes, nil
+}
+
+// columnDefinitions returns the structured columns of the table in declaration order
+func (t *TableRegistry) columnDefinitions() []columnDefinition {
+	definitions := []columnDefinition{}
+	for _, field := range t.columns() {
+		definitions = append(definitions, field.definition())
+	}
+	return definitions
+}
+
+var defaultPattern = regexp.MustCompile(`\bdefault\s+(.+?)(?:\s+(?:not null|null|unique|primary key|check|references|constraint)\b.*)?$`)
+
+func (f *TableFieldValues) definition() columnDefinition {
+	constraints := strings.ToLower(f.Constraints)
+	typ := strings.ToLower(strings.TrimSpace(f.Type))
+
+	definition := columnDefinition{
+		name:       f.Name,
+		typ:        normalizeColumnType(typ),
+		primaryKey: strings.Contains(constraints, "primary key"),
+		unique:     strings.Contains(constraints, "unique"),
+		serial:     strings.HasSuffix(typ, "serial") || strings.HasPrefix(typ, "serial"),
+		reference:  f.Reference,
+	}
+	definition.notNull = definition.primaryKey || definition.serial || strings.Contains(constraints, "not null")
+	if match := defaultPattern.FindStringSubmatch(constraints); match != nil {
+		definition.def = normalizeDefault(match[1])
+	}
+	return definition
+}
+
+var checkPattern = regexp.MustCompile(`(?i)\bcheck\s*\(.*\)`)
+
+// constraintDefinitions returns the single column constraints declared by the fields of the table followed by the table constraints.
+// Foreign keys named in deferred are deferrable.
+func (t *TableRegistry) constraintDefinitions(deferred map[string]bool) []databaseConstraint {
+	constraints := []databaseConstraint{}
+	for _, definition := range t.columnDefinitions() {
+		column := string(definition.name)
+		if definition.primaryKey {
+			constraints = append(constraints, databaseConstraint{name: objectName(string(t.TableName), "", "pkey"), kind: "p", columns: []string{column}})
+		}
+		if definition.unique {
+			constraints = append(constraints, databaseConstraint{name: objectName(string(t.TableName), column, "key"), kind: "u", columns: []string{column}})
+		}
+		if reference := definition.reference; reference != nil {
+			constraints = append(constraints, databaseConstraint{
+				name:       objectName(string(t.TableName), column, "fkey"),
+				kind:       "f",
+				columns:    []string{column},
+				refTable:   string(reference.Table),
+				refColumns: []string{string(reference.Column)},
+				onUpdate:   referenceAction(reference.OnUpdate),
+				onDelete:   referenceAction(reference.OnDelete),
+			})
+		}
+		if check := checkPattern.FindString(t.Fields[definition.name].Constraints); check != "" {
+			constraints = append(constraints, databaseConstraint{name: objectName(string(t.TableName), column, "check"), kind: "c", columns: []string{column}, expression: check})
+		}
+	}
+	constraints = append(constraints, t.tableConstraintDefinitions()...)
+	for i := range constraints {
+		constraints[i].deferrable = deferred[constraints[i].name]
+	}
+	return constraints
+}
+
+// equal compares everything but the names. Checks and exclusions are compared by name, their expressions are rewritten by PostgreSQL.
+func (c databaseConstraint) equal(other databaseConstraint) bool {
+	if c.kind == "c" || c.kind == "x" {
+		return c.kind == other.kind && c.name == other.name
+	}
+	return c.kind == other.kind &&
+		slices.Equal(c.columns, other.columns) &&
+		c.refTable == other.refTable &&
+		slices.Equal(c.refColumns, other.refColumns) &&
+		c.onUpdate == other.onUpdate &&
+		c.onDelete == other.onDelete
+}
+func (c databaseConstraint) definition() string {
+	name := QuoteIdentifier(c.name)
+	columns := quoteIdentifiers(c.columns...)
+	switch c.kind {
+	case "p":
+		return fmt.Sprintf("CONSTRAINT %s PRIMARY KEY (%s)", name, columns)
+	case "u":
+		return fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", name, columns)
+	case "c", "x":
+		return fmt.Sprintf("CONSTRAINT %s %s", name, c.expression)
+	}
+	definition := fmt.Sprintf(
+		"CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) ON UPDATE %s ON DELETE %s",
+		name, columns, QuoteIdentifier(c.refTable), quoteIdentifiers(c.refColumns...), c.onUpdate, c.onDelete,
+	)
+	if c.deferrable {
+		definition += " DEFERRABLE INITIALLY DEFERRED"
+	}
+	return definition
+}
+func referenceAction(action string) string {
+	if action == "" {
+		return "NO ACTION"
+	}
+	return strings.ToUpper(action)
+}
+
+var columnTypeAliases = map[string]string{
+	"int":         "integer",
+	"int4":        "integer",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"int8":        "bigint",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"int2":        "smallint",
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"bool":        "boolean",
+	"float":       "double precision",
+	"float8":      "double precision",
+	"float4":      "real",
+	"decimal":     "numeric",
+	"varchar":     "character varying",
+	"char":        "character",
+	"timestamptz": "timestamp with time zone",
+	"timestamp":   "timestamp without time zone",
+	"timetz":      "time with time zone",
+	"time":        "time without time zone",
+}
+
+// normalizeColumnType converts type aliases, element types of arrays included, to the names returned by format_type
+func normalizeColumnType(typ string) string {
+	typ = strings.Join(strings.Fields(strings.ToLower(strings.Trim(typ, `"`))), " ")
+	dimensions := ""
+	for strings.HasSuffix(typ, "[]") {
+		typ = strings.TrimSpace(strings.TrimSuffix(typ, "[]"))
+		dimensions += "[]"
+	}
+	base, modifier, _ := strings.Cut(typ, "(")
+	base = strings.TrimSpace(base)
+	if alias, ok := columnTypeAliases[base]; ok {
+		base = alias
+	}
+	if modifier != "" {
+		return base + "(" + strings.ReplaceAll(modifier, " ", "") + dimensions
+	}
+	if base == "character" {
+		return "character(1)" + dimensions
+	}
+	return base + dimensions
+}
+
+var castPattern = regexp.MustCompile(`::[a-z ]+(\(\d+(,\d+)?\))?(\[\])?$`)
+
+// normalizeDefault removes the casts and parentheses PostgreSQL adds to stored defaults
+func normalizeDefault(def string) string {
+	def = strings.ToLower(strings.TrimSpace(def))
+	for {
+		trimmed := castPattern.ReplaceAllString(def, "")
+		if strings.HasPrefix(trimmed, "(") && strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") == 1 {
+			trimmed = trimmed[1 : len(trimmed)-1]
+		}
+		if trimmed == def {
+			return def
+		}
+		def = trimmed
+	}
+}
+
+type databaseColumn struct {
+	name     TableFieldName
+	typ      string
+	notNull  bool
+	def      string
+	position int
+}
+
+// introspectColumns returns the columns of a table in the current schema. Returns nothing if the table doesn't exist.
+func introspectColumns(ctx context.Context, target doer, table TableName) (map[string]databaseColumn, error) {
+	columns := map[string]databaseColumn{}
+	query := newUnsafeQuery(SELECT, `SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, COALESCE(pg_get_expr(d.adbin, d.adrelid), ''), a.attnum
+FROM pg_catalog.pg_attribute a
+JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE c.relname = $1 AND c.relkind = 'r' AND n.nspname = current_schema() AND a.attnum > 0 AND NOT a.attisdropped`)
+	query.CurrentValues = append(query.CurrentValues, table)
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var column databaseColumn
+			if err := rows.Scan(&column.name, &column.typ, &column.notNull, &column.def, &column.position); err != nil {
+				return false, err
+			}
+			column.typ = normalizeColumnType(column.typ)
+			column.def = normalizeDefault(column.def)
+			columns[string(column.name)] = column
+		}
+		return true, rows.Err()
+	})
+	if err := target.DoContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// introspectConstraints returns the primary keys, unique constraints, foreign keys, checks and exclusions of a table sorted by name
+func introspectConstraints(ctx context.Context, target doer, table TableName) ([]databaseConstraint, error) {
+	constraints := []databaseConstraint{}
+	query := newUnsafeQuery(SELECT, `SELECT con.conname, con.contype::text,
+	ARRAY(SELECT a.attname::text FROM unnest(con.conkey) WITH ORDINALITY k(attnum, i) JOIN pg_catalog.pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum ORDER BY k.i),
+	COALESCE(ref.relname::text, ''),
+	ARRAY(SELECT a.attname::text FROM unnest(con.confkey) WITH ORDINALITY k(attnum, i) JOIN pg_catalog.pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum ORDER BY k.i),
+	con.confupdtype::text, con.confdeltype::text, pg_get_constraintdef(con.oid)
+FROM pg_catalog.pg_constraint con
+JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_catalog.pg_class ref ON ref.oid = con.confrelid
+WHERE c.relname = $1 AND n.nspname = current_schema() AND con.contype IN ('p', 'u', 'f', 'c', 'x')
+ORDER BY con.conname`)
+	query.CurrentValues = append(query.CurrentValues, table)
+	query.Scanner(func(rows *sql.Rows) (bool, error) {
+		defer rows.Close()
+		for rows.Next() {
+			var constraint databaseConstraint
+			var onUpdate, onDelete, expression string
+			err := rows.Scan(&constraint.name, &constraint.kind, pq.Array(&constraint.columns), &constraint.refTable, pq.Array(&constraint.refColumns), &onUpdate, &onDelete, &expression)
+			if err != nil {
+				return false, err
+			}
+			if constraint.kind == "c" || constraint.kind == "x" {
+				constraint.expression = expression
+			}
+			if constraint.kind == "f" {
+				constraint.onUpdate = databaseReferenceActions[onUpdate]
+				constraint.onDelete = databaseReferenceActions[onDelete]
+			} else {
+				constraint.refColumns = nil
+			}
+			constraints = append(constraints, constraint)
+		}
+		return true, rows.Err()
+	})
+	if err := target.DoContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return constraints, nil
+}
+
+// Actions of pg_constraint.confupdtype and confdeltype
+var databaseReferenceActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+// alterTable applies the safe changes of an existing table. Destructive changes are only logged.
+// Deferred foreign keys are left to migrateDeferredForeignKeys, the tables they reference may not exist yet.
+func (r *Commiter) alterTable(ctx context.Context, t *Transaction, table *TableRegistry, deferred map[string]bool) error {
+	changes, err := diffTable(ctx, t, table, deferred)
+	if err != nil {
+		return err
+	}
+	changes = slices.DeleteFunc(changes, func(c SchemaChange) bool {
+		return c.Kind == CHANGE_ADD_FOREIGN_KEY && deferred[c.Object]
+	})
+	if err := applySchemaChanges(ctx, t, changes, false); err != nil {
+		return errors.Join(ErrorDescription(ErrFailedOperation, fmt.Sprintf("Unable to alter table %s", table.TableName)), err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package borm
+
+import "testing"
+
+func TestNormalizeColumnType(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want string
+	}{
+		{"INT", "integer"},
+		{"serial", "integer"},
+		{"bigserial", "bigint"},
+		{"bool", "boolean"},
+		{"text", "text"},
+		{`"Timestamptz"`, "timestamp with time zone"},
+		{"timestamp", "timestamp without time zone"},
+		{"character  varying", "character varying"},
+		{"varchar(20)", "character varying(20)"},
+		{"numeric(10, 2)", "numeric(10,2)"},
+		{"decimal(10,2)", "numeric(10,2)"},
+		{"char", "character(1)"},
+		{"char(3)", "character(3)"},
+		{"text[]", "text[]"},
+		{"int[]", "integer[]"},
+		{"int4[][]", "integer[][]"},
+		{"char[]", "character(1)[]"},
+		{"varchar(20)[]", "character varying(20)[]"},
+	}
+	for _, test := range tests {
+		t.Run(test.typ, func(t *testing.T) {
+			if got := normalizeColumnType(test.typ); got != test.want {
+				t.Errorf("normalizeColumnType(%q) = %q, want %q", test.typ, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	tests := []struct {
+		def  string
+		want string
+	}{
+		{"0", "0"},
+		{"(-1)", "-1"},
+		{"now()", "now()"},
+		{"NOW()", "now()"},
+		{"'abc'::text", "'abc'"},
+		{"'abc'::character varying", "'abc'"},
+		{"'1.5'::numeric(10,2)", "'1.5'"},
+		{"'{}'::integer[]", "'{}'"},
+		{"('now'::text)::timestamp with time zone", "'now'"},
+		{"nextval('users_id_seq'::regclass)", "nextval('users_id_seq'::regclass)"},
+		{"lower(name)", "lower(name)"},
+	}
+	for _, test := range tests {
+		t.Run(test.def, func(t *testing.T) {
+			if got := normalizeDefault(test.def); got != test.want {
+				t.Errorf("normalizeDefault(%q) = %q, want %q", test.def, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package borm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaSQL returns the statements that create every registered enum, table and index, tables after the ones they need.
+// The output only changes with the registry: tables are sorted by name and columns follow the declaration order of the structs.
+// Foreign keys deferred by DeferCyclicForeignKeys are added at the end.
+func (m *Commiter) SchemaSQL() (string, error) {
+	tables, deferred, err := m.orderedTables()
+	if err != nil {
+		return "", err
+	}
+
+	statements := []string{}
+	enums := map[TypName]bool{}
+	for _, table := range tables {
+		for _, typ := range table.RequiredTypes {
+			enum, ok := typ.(*Enum)
+			if !ok || enums[enum.Name] {
+				continue
+			}
+			enums[enum.Name] = true
+			statements = append(statements, parseCreateEnumQuery(enum).build())
+		}
+	}
+	for _, table := range tables {
+		statements = append(statements, parseCreateTableQuery(table, deferred[table.TableName]).build())
+		if Settings().Timestamps().GetSource() == DATABASE_TRIGGER {
+			for _, query := range parseTimestampTriggerQueries(table) {
+				statements = append(statements, query.build())
+			}
+		}
+		for _, index := range table.indexDefinitions() {
+			statements = append(statements, parseCreateIndexQuery(index).build())
+		}
+	}
+	for _, table := range tables {
+		for _, constraint := range table.deferredConstraints(deferred[table.TableName]) {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s;", QuoteIdentifier(string(table.TableName)), constraint.definition()))
+		}
+	}
+	return strings.Join(statements, "\n\n") + "\n", nil
+}
@@ -1,9 +1,11 @@
 package borm
 
 import (
+	"cmp"
 	"fmt"
 	"maps"
 	"reflect"
+	"slices"
 	"strings"
 )
 
@@ -15,6 +17,10 @@ type TableRegistry struct {
 
 	RequiredTypes  []TypMethods
 	RequiredTables []*TableRegistry
+	// Indexes declared with Index and UniqueIndex, the ones of field tags are not included
+	Indexes []*TableIndex
+	// Constraints over many columns, the ones of field tags are not included
+	Constraints []*TableConstraint
 
 	databaseCache *TablesCache
 }
@@ -25,7 +31,14 @@ type TableFieldValues struct {
 	Type        string
 	Constraints string
 	ForeignKey  string
+	Reference   *FieldReference
+	Index       *FieldIndex
 	Ignore      bool
+	// Declaration order in the struct, fields of embedded structs take the place of the embedded field
+	Position int
+
+	AutoCreateTime bool
+	AutoUpdateTime bool
 }
 
 func NewTableRegistry(name string) *TableRegistry {
@@ -57,6 +70,7 @@ func (m *TablesCache) RegisterTable(v any) *TableRegistry {
 		Fields:        parseFields(Type),
 		databaseCache: m,
 	}
+	registry.Error = registry.validateIdentifiers()
 	(*m)[tableName] = registry
 
 	return registry
@@ -65,8 +79,22 @@ func (t *TableRegistry) Name(n string) *TableRegistry {
 	delete(*t.databaseCache, t.TableName)
 	t.TableName = TableName(n)
 	(*t.databaseCache)[t.TableName] = t
+	if err := validateIdentifier("table", n); err != nil {
+		t.Error = err
+	}
 	return t
 }
+func (t *TableRegistry) validateIdentifiers() error {
+	if err := validateIdentifier("table", string(t.TableName)); err != nil {
+		return err
+	}
+	for _, field := range t.columns() {
+		if err := validateIdentifier("column", string(field.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func (t *TableRegistry) NeedTables(dependencies ...*TableRegistry) *TableRegistry {
 	for _, dependency := range dependencies {
 		if _, ok := (*t.databaseCache)[dependency.TableName]; !ok {
@@ -81,10 +109,30 @@ func (t *TableRegistry) NeedRoles(dependencies ...TypMethods) *TableRegistry {
 	t.RequiredTypes = append(t.RequiredTypes, dependencies...)
 	return t
 }
+
+// inferEnums adds the enums of types used as column types to the required types of the table
+func (t *TableRegistry) inferEnums(types *TypesCache) {
+	for _, field := range t.columns() {
+		typ, ok := (*types)[TypName(strings.ToLower(strings.Trim(field.Type, `"`)))]
+		if !ok {
+			continue
+		}
+		required := slices.ContainsFunc(t.RequiredTypes, func(r TypMethods) bool {
+			return r.GetName() == typ.GetName()
+		})
+		if !required {
+			t.RequiredTypes = append(t.RequiredTypes, typ)
+		}
+	}
+}
 func (m *TableRegistry) Update() *Query {
 	q := NewQuery(m, UPDATE)
+	if q.Error != nil {
+		return q
+	}
 	q.tableAliases[""] = m
-	q.appendQueryBlock(fmt.Sprintf("UPDATE %s", m.TableName))
+	q.autoFields = m.timestampFields(UPDATE)
+	q.appendQueryBlock(fmt.Sprintf("UPDATE %s", QuoteIdentifier(string(m.TableName))))
 
 	return q
 }
@@ -97,10 +145,11 @@ func (m *TableRegistry) SelectDistinct(fieldsName ...string) *AdditionalSelectQu
 	// Maps the register of this table as anonymous alias until it gets an alias
 	q.tableAliases[""] = m
 	q.selectorFields = append(q.selectorFields, fieldsName...)
+	q.selectedFields = fieldsName
 
 	q.Type = SELECT
-	q.appendQueryBlock(fmt.Sprintf("SELECT DISTINCT %s", strings.Join(fieldsName, ", ")))
-	q.appendQueryBlock(fmt.Sprintf("FROM %s", q.TableRegistry.TableName))
+	q.appendQueryBlock(fmt.Sprintf("SELECT DISTINCT %s", quoteFields(fieldsName...)))
+	q.appendQueryBlock(fmt.Sprintf("FROM %s", QuoteIdentifier(string(q.TableRegistry.TableName))))
 	return newAdditionalSelectQuery(q)
 }
 func (m *TableRegistry) Select(fieldsName ...string) *AdditionalSelectQuery {
@@ -112,10 +161,11 @@ func (m *TableRegistry) Select(fieldsName ...string) *AdditionalSelectQuery {
 	// Maps the register of this table as anonymous alias until it gets an alias
 	q.tableAliases[""] = m
 	q.selectorFields = append(q.selectorFields, fieldsName...)
+	q.selectedFields = fieldsName
 
 	q.Type = SELECT
-	q.appendQueryBlock(fmt.Sprintf("SELECT %s", strings.Join(fieldsName, ", ")))
-	q.appendQueryBlock(fmt.Sprintf("FROM %s", q.TableRegistry.TableName))
+	q.appendQueryBlock(fmt.Sprintf("SELECT %s", quoteFields(fieldsName...)))
+	q.appendQueryBlock(fmt.Sprintf("FROM %s", QuoteIdentifier(string(q.TableRegistry.TableName))))
 	return newAdditionalSelectQuery(q)
 }
 func (m *TableRegistry) Insert(fieldsName ...string) *Query {
@@ -128,7 +178,10 @@ func (m *TableRegistry) Insert(fieldsName ...string) *Query {
 
 	q.Type = INSERT
 	q.requiredValueLength = len(fieldsName)
-	q.appendQueryBlock(fmt.Sprintf("INSERT INTO %s (%s)", q.TableRegistry.TableName, strings.Join(fieldsName, ", ")))
+	q.autoFields = missingFields(m.timestampFields(INSERT), fieldsName)
+
+	columns := append(slices.Clone(fieldsName), q.autoFields...)
+	q.appendQueryBlock(fmt.Sprintf("INSERT INTO %s (%s)", QuoteIdentifier(string(q.TableRegistry.TableName)), quoteNames(columns...)))
 	return q
 }
 func (m *TableRegistry) Delete() *Query {
@@ -137,11 +190,38 @@ func (m *TableRegistry) Delete() *Query {
 		return q
 	}
 	q.tableAliases[""] = m
-	q.appendQueryBlock(fmt.Sprintf("DELETE FROM %s", q.TableRegistry.TableName))
+	q.appendQueryBlock(fmt.Sprintf("DELETE FROM %s", QuoteIdentifier(string(q.TableRegistry.TableName))))
 	return q
 }
 
+// columnType returns the type of a field for a column definition. Quotes it if it refers to a required type.
+func (t *TableRegistry) columnType(field *TableFieldValues) string {
+	for _, typ := range t.RequiredTypes {
+		if string(typ.GetName()) == field.Type {
+			return QuoteIdentifier(field.Type)
+		}
+	}
+	return field.Type
+}
+
+// columns returns the fields that are not ignored in declaration order
+func (t *TableRegistry) columns() []*TableFieldValues {
+	columns := []*TableFieldValues{}
+	for _, field := range t.Fields {
+		if !field.Ignore {
+			columns = append(columns, field)
+		}
+	}
+	slices.SortFunc(columns, func(a, b *TableFieldValues) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), strings.Compare(string(a.Name), string(b.Name)))
+	})
+	return columns
+}
 func parseFields(Type reflect.Type) map[TableFieldName]*TableFieldValues {
+	position := 0
+	return parseFieldsFrom(Type, &position)
+}
+func parseFieldsFrom(Type reflect.Type, position *int) map[TableFieldName]*TableFieldValues {
 	fields := map[TableFieldName]*TableFieldValues{}
 
 	tagReader := newTagReader()
@@ -153,7 +233,7 @@ func parseFields(Type reflect.Type) map[TableFieldName]*TableFieldValues {
 		}
 		// Copy the embedded struct fields
 		if structField.Anonymous && Type.Kind() == reflect.Struct {
-			maps.Copy(fields, parseFields(Type))
+			maps.Copy(fields, parseFieldsFrom(Type, position))
 			continue
 		}
 		fieldName := TableFieldName(strings.ToLower(structField.Name))
@@ -161,11 +241,22 @@ func parseFields(Type reflect.Type) map[TableFieldName]*TableFieldValues {
 		field := tagReader.
 			Override(newTableFieldValues(fieldName, fieldType)).
 			Read(structField)
+		field.Position = *position
+		*position++
 
 		fields[field.Name] = field
 	}
 	return fields
 }
+
+// FieldReference is the structured form of a (FOREIGN KEY, table, column) tag
+type FieldReference struct {
+	Table    TableName
+	Column   TableFieldName
+	OnUpdate string
+	OnDelete string
+}
+
 func newTableFieldValues(name TableFieldName, Type string) *TableFieldValues {
 	return &TableFieldValues{
 		Name: name,
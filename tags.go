@@ -79,7 +79,11 @@ func (m *TagReader) Read(f reflect.StructField) *TableFieldValues {
 	field := newTableFieldValues(tag.GetName(), tag.GetType())
 	field.Constraints = tag.GetConstraints()
 	field.ForeignKey = tag.GetForeignKey(field.Name)
+	field.Reference = tag.GetReference()
+	field.Index = tag.GetIndex()
 	field.Ignore = tag.GetIgnore()
+	field.AutoCreateTime = tag.GetAutoCreateTime()
+	field.AutoUpdateTime = tag.GetAutoUpdateTime()
 
 	return field
 }
@@ -93,6 +97,18 @@ func (t *Tag) GetIgnore() bool {
 	}
 	return false
 }
+func (t *Tag) GetAutoCreateTime() bool {
+	if values := t.values["AUTO CREATE TIME"]; len(values) > 0 {
+		return true
+	}
+	return false
+}
+func (t *Tag) GetAutoUpdateTime() bool {
+	if values := t.values["AUTO UPDATE TIME"]; len(values) > 0 {
+		return true
+	}
+	return false
+}
 func (t *Tag) GetName() TableFieldName {
 	if values := t.values["NAME"]; len(values) > 0 {
 		return TableFieldName(values[0])
@@ -115,7 +131,7 @@ func (t *Tag) GetForeignKey(f TableFieldName) string {
 		return ""
 	}
 
-	var foreignKey string = fmt.Sprintf("\n\tFOREIGN KEY (%s)\n\tREFERENCES %s (%s)", f, values[0], values[1])
+	var foreignKey string = fmt.Sprintf("\n\tFOREIGN KEY (%s)\n\tREFERENCES %s (%s)", QuoteIdentifier(string(f)), QuoteIdentifier(values[0]), QuoteIdentifier(values[1]))
 
 	values = t.values["UPDATE"]
 	if len(values) > 0 {
@@ -129,6 +145,36 @@ func (t *Tag) GetForeignKey(f TableFieldName) string {
 
 	return foreignKey
 }
+func (t *Tag) GetReference() *FieldReference {
+	values := t.values["FOREIGN KEY"]
+	if len(values) < 2 {
+		return nil
+	}
+
+	reference := &FieldReference{Table: TableName(values[0]), Column: TableFieldName(values[1])}
+	if values := t.values["UPDATE"]; len(values) > 0 {
+		reference.OnUpdate = strings.ToUpper(values[0])
+	}
+	if values := t.values["DELETE"]; len(values) > 0 {
+		reference.OnDelete = strings.ToUpper(values[0])
+	}
+	return reference
+}
+func (t *Tag) GetIndex() *FieldIndex {
+	index := &FieldIndex{}
+	values := t.values["INDEX"]
+	if unique := t.values["UNIQUE INDEX"]; len(unique) > 0 {
+		index.Unique = true
+		values = unique
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if values[0] != "-" {
+		index.Name = values[0]
+	}
+	return index
+}
 func parseFieldType(typname string) string {
 	switch typname {
 	case reflect.TypeFor[string]().Name():
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
@@ -17,6 +18,7 @@ func main() {
 		switch arg {
 		case "--debug":
 			borm.Settings().Environment().SetEnvironment(borm.DEBUGGING)
+			borm.Settings().Logging().SetLogger(slog.New(slog.NewTextHandler(os.Stdout, nil)))
 		case "--migrate":
 			borm.Settings().Migrations().Enable().RecreateExisting().UndoOnError()
 		}
@@ -53,7 +55,7 @@ func main() {
 	USER_ROLES := development.RegisterEnum("user_role", STUDENT, TEACHER, ADMIN)
 	TABLE_USERS := development.RegisterTable(Users{}).NeedRoles(USER_ROLES)
 	TABLE_NOTIFICATIONS := development.RegisterTable(Notifications{}).NeedTables(TABLE_USERS)
-	TABLE_USERS_NOTIFICATIONS := development.RegisterTable(UsersNotifications{}).Name("users_notifications").NeedTables(TABLE_USERS, TABLE_NOTIFICATIONS)
+	TABLE_USERS_NOTIFICATIONS := development.RegisterTable(UsersNotifications{}).Name("users_notifications").NeedTables(TABLE_USERS, TABLE_NOTIFICATIONS).PrimaryKey("user_id", "notification_id")
 
 	// DEVELOPMENT_USER.GrantPrivileges(TABLE_USERS, borm.ALL)
 
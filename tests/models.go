@@ -26,8 +26,8 @@ type Users struct {
 	SpecificWordC string `borm:"(NAME, specific_c)"`
 
 	DeletedAt time.Time `borm:"(NAME, deleted_at)"`
-	UpdatedAt time.Time `borm:"(NAME, updated_at)"`
-	CreatedAt time.Time `borm:"(NAME, created_at)"`
+	UpdatedAt time.Time `borm:"(NAME, updated_at) (AUTO UPDATE TIME)"`
+	CreatedAt time.Time `borm:"(NAME, created_at) (AUTO CREATE TIME)"`
 }
 type Notifications struct {
 	Id          int    `borm:"(TYPE, SERIAL) (CONSTRAINTS, PRIMARY KEY)"`
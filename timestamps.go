@@ -0,0 +1,143 @@
+package borm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// autoSet locates an automatic timestamp assignment inside an UPDATE query.
+// value is -1 when the assignment does not use a placeholder.
+type autoSet struct {
+	block int
+	value int
+}
+
+// timestampFields returns the fields filled automatically by queries of type typ.
+// Returns nothing when timestamps are managed by a database trigger.
+func (t *TableRegistry) timestampFields(typ QueryType) []string {
+	if Settings().Timestamps().GetSource() == DATABASE_TRIGGER {
+		return nil
+	}
+
+	fields := []string{}
+	for _, field := range t.Fields {
+		if field.Ignore {
+			continue
+		}
+		if field.AutoUpdateTime || (typ == INSERT && field.AutoCreateTime) {
+			fields = append(fields, string(field.Name))
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// missingFields returns the fields that are not present in explicit
+func missingFields(fields []string, explicit []string) []string {
+	missing := []string{}
+	for _, field := range fields {
+		if !slices.Contains(explicit, field) {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// useTimestamp returns the value block of an automatic timestamp based on the configured source
+func (q *Query) useTimestamp(now time.Time) string {
+	if Settings().Timestamps().GetSource() == CLIENT_CLOCK {
+		return q.usePlaceholder(now)
+	}
+	return "now()"
+}
+
+// appendAutoSets appends the automatic timestamps of an UPDATE query, except the field being explicitly set
+func (q *Query) appendAutoSets(explicit string) {
+	now := time.Now()
+	for _, field := range q.autoFields {
+		if field == explicit {
+			continue
+		}
+		q.appendQueryBlock(",")
+
+		value := q.useTimestamp(now)
+		set := autoSet{block: len(q.Blocks), value: -1}
+		if strings.HasPrefix(value, "$") {
+			set.value = len(q.CurrentValues) - 1
+		}
+		q.appendQueryBlock(fmt.Sprintf("%s = %s", QuoteIdentifier(field), value))
+		q.autoSets[field] = set
+	}
+}
+
+// overrideAutoSet replaces an automatic timestamp with an explicit value. Returns false if field is not automatic.
+func (q *Query) overrideAutoSet(field string, value any) bool {
+	set, ok := q.autoSets[field]
+	if !ok {
+		return false
+	}
+	delete(q.autoSets, field)
+
+	if set.value >= 0 {
+		q.CurrentValues[set.value] = value
+		return true
+	}
+	q.Blocks[set.block].Block = fmt.Sprintf("%s = %s", QuoteIdentifier(field), q.usePlaceholder(value))
+	return true
+}
+
+// parseTimestampTriggerQueries returns the queries that install or replace the timestamp trigger of a table.
+// Returns nothing if the table has no automatic timestamps.
+// A trigger can't tell which columns an UPDATE sets, so an explicit value equal to the old one is replaced by now().
+func parseTimestampTriggerQueries(table *TableRegistry) []*Query {
+	var onInsert, onUpdate []string
+	for _, field := range table.Fields {
+		if field.Ignore {
+			continue
+		}
+		column := QuoteIdentifier(string(field.Name))
+		if field.AutoCreateTime || field.AutoUpdateTime {
+			onInsert = append(onInsert, fmt.Sprintf("\n\t\tIF NEW.%s IS NULL THEN NEW.%s := now(); END IF;", column, column))
+		}
+		if field.AutoUpdateTime {
+			onUpdate = append(onUpdate, fmt.Sprintf("\n\t\tIF NEW.%s IS NOT DISTINCT FROM OLD.%s THEN NEW.%s := now(); END IF;", column, column, column))
+		}
+	}
+	if len(onInsert) == 0 {
+		return nil
+	}
+	slices.Sort(onInsert)
+	slices.Sort(onUpdate)
+
+	name := QuoteIdentifier(fmt.Sprintf("borm_%s_timestamps", table.TableName))
+	tableName := QuoteIdentifier(string(table.TableName))
+	function := fmt.Sprintf(
+		"CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$\nBEGIN\n\tIF TG_OP = 'INSERT' THEN%s\n\tELSE%s\n\tEND IF;\n\tRETURN NEW;\nEND;\n$$ LANGUAGE plpgsql;",
+		name, strings.Join(onInsert, ""), strings.Join(onUpdate, ""),
+	)
+	return []*Query{
+		newUnsafeQuery(CREATE, function),
+		newUnsafeQuery(DROP, fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s;", name, tableName)),
+		newUnsafeQuery(CREATE, fmt.Sprintf("CREATE TRIGGER %s BEFORE INSERT OR UPDATE ON %s FOR EACH ROW EXECUTE FUNCTION %s();", name, tableName, name)),
+	}
+}
+
+// migrateTimestampTrigger installs the timestamp trigger of a created or altered table when timestamps come from the database
+func (r *Commiter) migrateTimestampTrigger(ctx context.Context, t *Transaction, table *TableRegistry) error {
+	queries := parseTimestampTriggerQueries(table)
+	if Settings().Timestamps().GetSource() != DATABASE_TRIGGER || len(queries) == 0 {
+		return nil
+	}
+
+	var err error
+	for _, query := range queries {
+		if err = doMigration(ctx, t, query); err != nil {
+			break
+		}
+	}
+	logMigration(ctx, "trigger", fmt.Sprintf("borm_%s_timestamps", table.TableName), "create", err)
+	return err
+}
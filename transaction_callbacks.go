@@ -0,0 +1,68 @@
+package borm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// OnCommit registers fn to run after the transaction commits. Callbacks run in registration order.
+//
+// Callbacks of nested transactions run only when the outermost transaction commits.
+// A panicking callback doesn't stop the others and doesn't change the result of the transaction, it is logged as ErrCallbackPanic.
+func (t *Transaction) OnCommit(fn func()) {
+	t.onCommit = append(t.onCommit, fn)
+}
+
+// OnRollback registers fn to run after the transaction rolls back, including rollbacks caused by failed queries. Callbacks run in registration order.
+//
+// Callbacks of nested transactions run when their savepoint or any of their parents roll back, even if the nested transaction is still open.
+func (t *Transaction) OnRollback(fn func()) {
+	t.onRollback = append(t.onRollback, fn)
+}
+
+// runCallbacks runs every callback even if some of them panic.
+// Panics are recovered and logged as ErrCallbackPanic, the transaction already finished and its result is not changed by them.
+func (t *Transaction) runCallbacks(event string, callbacks []func()) {
+	t.onCommit = nil
+	t.onRollback = nil
+
+	for i, callback := range callbacks {
+		if err := runCallback(i, callback); err != nil {
+			logRecord(context.Background(), "borm transaction callback", err, slog.String("event", event))
+		}
+	}
+}
+func runCallback(index int, callback func()) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = ErrorDescription(ErrCallbackPanic, fmt.Sprintf("Callback %d: %v", index, recovered))
+		}
+	}()
+	callback()
+	return nil
+}
+
+// absorbNested finishes the nested transactions still open inside t with the outcome of t. Their callbacks become callbacks of t.
+func (t *Transaction) absorbNested(state TransactionState) {
+	for _, nested := range t.nested {
+		if nested.state != TRANSACTION_ACTIVE {
+			continue
+		}
+		nested.absorbNested(state)
+		nested.state = state
+		t.onCommit = append(t.onCommit, nested.onCommit...)
+		t.onRollback = append(t.onRollback, nested.onRollback...)
+		nested.onCommit = nil
+		nested.onRollback = nil
+	}
+	t.nested = nil
+}
+
+// releaseCallbacks hands the callbacks of a released nested transaction to its parent
+func (t *Transaction) releaseCallbacks() {
+	t.parent.onCommit = append(t.parent.onCommit, t.onCommit...)
+	t.parent.onRollback = append(t.parent.onRollback, t.onRollback...)
+	t.onCommit = nil
+	t.onRollback = nil
+}
@@ -0,0 +1,144 @@
+package borm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type IsolationLevel int
+
+const (
+	DEFAULT_ISOLATION IsolationLevel = iota
+	READ_COMMITTED
+	REPEATABLE_READ
+	SERIALIZABLE
+)
+
+// TxOptions configures how a transaction is started.
+//
+// Deferrable only takes effect on SERIALIZABLE and ReadOnly transactions, it waits until the transaction can run without serialization failures.
+type TxOptions struct {
+	Isolation  IsolationLevel
+	ReadOnly   bool
+	Deferrable bool
+}
+
+// RetryPolicy configures how InTxRetry re-executes transactions. Zero values are replaced by defaults.
+type RetryPolicy struct {
+	// Amount of executions, including the first one. Defaults to 3.
+	Attempts int
+	// Wait before the first retry, doubled on each retry. Defaults to 10ms.
+	BaseDelay time.Duration
+	// Maximum wait between retries. Defaults to 1s.
+	MaxDelay time.Duration
+}
+
+const (
+	sqlstateSerializationFailure = "40001"
+	sqlstateDeadlockDetected     = "40P01"
+)
+
+// StartTxWith starts a transaction with the given options and returns it.
+func (m *TransactionFactory) StartTxWith(ctx context.Context, options TxOptions) (*Transaction, error) {
+	tx, err := m.database.BeginTx(ctx, options.sqlOptions())
+	if err != nil {
+		return nil, errors.Join(ErrFailedTransactionStart, err)
+	}
+
+	if options.Deferrable {
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION DEFERRABLE"); err != nil {
+			return nil, errors.Join(ErrFailedTransactionStart, err, tx.Rollback())
+		}
+	}
+	t := NewTransaction(tx)
+	t.pipeline = m.pipeline
+	return t, nil
+}
+
+// InTxWith works like InTx but starts the transaction with the given options.
+func (m *TransactionFactory) InTxWith(ctx context.Context, options TxOptions, fn func(tx *Transaction) error) error {
+	t, err := m.StartTxWith(ctx, options)
+	if err != nil {
+		return err
+	}
+	return runTx(ctx, t, fn)
+}
+
+// InTxRetry works like InTxWith but re-executes the whole transaction when it fails with a serialization failure or a deadlock.
+// fn may run more than once, it must not have side effects outside the transaction.
+func (m *TransactionFactory) InTxRetry(ctx context.Context, options TxOptions, policy RetryPolicy, fn func(tx *Transaction) error) error {
+	policy = policy.withDefaults()
+
+	var err error
+	for attempt := range policy.Attempts {
+		err = m.InTxWith(ctx, options, fn)
+		if err == nil || !IsRetryableError(err) {
+			return err
+		}
+		if attempt == policy.Attempts-1 {
+			break
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+	return ErrorJoin(ErrorDescription(ErrFailedTransaction, fmt.Sprintf("Gave up after %d attempts", policy.Attempts)), err)
+}
+
+// IsRetryableError returns true if err was caused by a serialization failure (SQLSTATE 40001) or a deadlock (SQLSTATE 40P01).
+func IsRetryableError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == sqlstateSerializationFailure || pqErr.Code == sqlstateDeadlockDetected
+}
+
+func (o TxOptions) sqlOptions() *sql.TxOptions {
+	options := &sql.TxOptions{ReadOnly: o.ReadOnly}
+	switch o.Isolation {
+	case READ_COMMITTED:
+		options.Isolation = sql.LevelReadCommitted
+	case REPEATABLE_READ:
+		options.Isolation = sql.LevelRepeatableRead
+	case SERIALIZABLE:
+		options.Isolation = sql.LevelSerializable
+	default:
+		options.Isolation = sql.LevelDefault
+	}
+	return options
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 10 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = time.Second
+	}
+	return p
+}
+
+// delay returns the jittered wait before the retry following attempt, between half and all of the exponential backoff
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	backoff := p.MaxDelay
+	if shifted := p.BaseDelay << attempt; attempt < 32 && shifted > 0 && shifted < p.MaxDelay {
+		backoff = shifted
+	}
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
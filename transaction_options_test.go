@@ -0,0 +1,32 @@
+package borm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		backoff time.Duration
+	}{
+		{"first attempt", RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 0, 10 * time.Millisecond},
+		{"doubles", RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 3, 80 * time.Millisecond},
+		{"capped", RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 7, time.Second},
+		{"overflow", RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 62, time.Second},
+		{"large attempt", RetryPolicy{BaseDelay: time.Nanosecond, MaxDelay: time.Minute}, 100, time.Minute},
+		{"defaults", RetryPolicy{}.withDefaults(), 1, 20 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for range 100 {
+				delay := test.policy.delay(test.attempt)
+				if delay < test.backoff/2 || delay > test.backoff {
+					t.Fatalf("delay(%d) = %v, want between %v and %v", test.attempt, delay, test.backoff/2, test.backoff)
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@
 package borm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 )
@@ -21,19 +22,62 @@ import (
 // Methods on Transaction created with a nil pointer will commit at the end of operation.
 // Methods on Transaction created with an already started transactions won't commit at the end of operation and will execute in the transaction.
 type Transaction struct {
-	tx *sql.Tx
+	tx       *sql.Tx
+	state    TransactionState
+	pipeline *ExecutionPipeline
+
+	// Set on nested transactions, which are savepoints of their parent
+	parent     *Transaction
+	savepoint  string
+	savepoints int
+
+	onCommit   []func()
+	onRollback []func()
+	// Nested transactions created from this one, finished along with it if they are still open
+	nested []*Transaction
 }
 
+// TransactionState tells if a transaction can still be used
+type TransactionState int
+
+const (
+	TRANSACTION_ACTIVE TransactionState = iota
+	TRANSACTION_COMMITTED
+	TRANSACTION_ROLLED_BACK
+)
+
 // NewTransaction creates a transaction. If a tx is != nil all operations will be done in its context and won't commit at the end.
 func NewTransaction(tx *sql.Tx) *Transaction {
 	return &Transaction{
-		tx: tx,
+		tx:       tx,
+		state:    TRANSACTION_ACTIVE,
+		pipeline: newExecutionPipeline(),
 	}
 }
 
+func (t *Transaction) State() TransactionState {
+	return t.state
+}
+
+// active returns true if the transaction and all its parents can still be used
+func (t *Transaction) active() bool {
+	if t.state != TRANSACTION_ACTIVE {
+		return false
+	}
+	return t.parent == nil || t.parent.active()
+}
+
+// Do executes the query in the transaction. Returns ErrTransactionDone if the transaction was already committed or rolled back.
+//
+// The transaction is rolled back if the query fails on execution.
 func (t *Transaction) Do(query *Query) error {
-	if err := query.isValid(); err != nil {
-		return err
+	return t.DoContext(context.Background(), query)
+}
+
+// DoContext works like Do. The query is canceled when ctx is done or its timeout expires, which also rolls back the transaction.
+func (t *Transaction) DoContext(ctx context.Context, query *Query) error {
+	if !t.active() {
+		return ErrorDescription(ErrTransactionDone, "Unable to use a finished transaction")
 	}
 	if query == nil {
 		return ErrorDescription(ErrSyntax, "Failed operation, cannot use empty queries")
@@ -41,65 +85,98 @@ func (t *Transaction) Do(query *Query) error {
 	if query.Error != nil {
 		return query.Error
 	}
+	if err := query.isValid(); err != nil {
+		return err
+	}
+
+	queryCtx, cancel := query.context(ctx)
+	defer cancel()
 
-	stmt, err := t.tx.Prepare(query.build())
+	execution, err := t.pipeline.execute(queryCtx, t.tx, query)
 	if err != nil {
-		err := ErrorJoin(ErrSyntax, err)
-		if Settings().Environment().GetEnvironment() == DEBUGGING {
-			ErrorJoin(err, errors.New("\n[Query]: "+query.build()))
+		if execution.failed {
+			return errors.Join(err, t.rollback(ctx))
 		}
 		return err
 	}
-
-	if query.RowsScanner != nil {
-		return t.query(stmt, query)
-	}
-	return t.exec(stmt, query.CurrentValues...)
+	return query.checkFound(execution.found)
 }
 
+// Commit commits the transaction. Nested transactions release their savepoint instead and are only committed along with their parent.
 func (t *Transaction) Commit() error {
+	if !t.active() {
+		return ErrorDescription(ErrTransactionDone, "Unable to commit a finished transaction")
+	}
+	if t.parent != nil {
+		if err := t.Release(t.savepoint); err != nil {
+			return ErrorDescription(ErrFailedTransactionCommit, err.Error())
+		}
+		t.state = TRANSACTION_COMMITTED
+		t.absorbNested(TRANSACTION_COMMITTED)
+		t.releaseCallbacks()
+		return nil
+	}
 	if err := t.tx.Commit(); err != nil {
-		return ErrorDescription(ErrFailedTransactionCommit, err.Error())
+		// A failed commit can't be retried, the transaction is rolled back by the database
+		t.state = TRANSACTION_ROLLED_BACK
+		t.absorbNested(TRANSACTION_ROLLED_BACK)
+		t.runCallbacks("rollback", t.onRollback)
+		return ErrorJoin(ErrFailedTransactionCommit, err)
 	}
 
+	t.state = TRANSACTION_COMMITTED
+	t.absorbNested(TRANSACTION_COMMITTED)
+	t.runCallbacks("commit", t.onCommit)
 	return nil
 }
 
-func (t *Transaction) query(stmt *sql.Stmt, query *Query) error {
-	rows, err := stmt.Query(query.CurrentValues...)
-	if err != nil {
-		return ErrorJoin(ErrorDescription(ErrFailedTransaction, err.Error()), t.rollback())
+// Rollback aborts the transaction. Nested transactions only roll back to their savepoint.
+// Returns ErrTransactionDone if the transaction was already committed or rolled back.
+func (t *Transaction) Rollback() error {
+	if !t.active() {
+		return ErrorDescription(ErrTransactionDone, "Unable to rollback a finished transaction")
 	}
-
-	found, err := query.Scan(rows)
-	if err != nil {
-		return err
-	}
-	// Found, Throw Error On Found
-	if found && query.throwErrorOnFound {
-		return ErrorDescription(ErrFound, "Rows found")
-	}
-	// Not Found, Default Throw Error On Not Found
-	if !found && !query.throwErrorOnFound {
-		return ErrorDescription(ErrNotFound, "No rows found")
-	}
-
-	return nil
+	return t.rollback(context.Background())
 }
 
-func (t *Transaction) exec(stmt *sql.Stmt, args ...any) error {
-	_, err := stmt.Exec(args...)
-	if err != nil {
-		return ErrorJoin(ErrorDescription(ErrFailedTransaction, "Transaction failed", err.Error()), t.rollback())
+// rollback uses ctx to roll back nested transactions to their savepoint
+func (t *Transaction) rollback(ctx context.Context) error {
+	t.state = TRANSACTION_ROLLED_BACK
+
+	var err error
+	if t.parent != nil {
+		err = t.rollbackSavepoint(ctx)
+	} else if rollbackErr := t.tx.Rollback(); rollbackErr != nil {
+		err = ErrorDescription(ErrFailedTransactionRollback, rollbackErr.Error())
 	}
-	return nil
+	t.absorbNested(TRANSACTION_ROLLED_BACK)
+	t.runCallbacks("rollback", t.onRollback)
+	return err
 }
 
-func (t *Transaction) rollback() error {
-	if err := t.tx.Rollback(); err != nil {
-		return ErrorDescription(ErrFailedTransactionRollback, err.Error())
+// runTx runs fn inside t. Commits if fn returns nil, rolls back if fn returns an error or panics.
+// Panics are propagated after the rollback.
+func runTx(ctx context.Context, t *Transaction, fn func(tx *Transaction) error) error {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if t.active() {
+				t.rollback(ctx)
+			}
+			panic(recovered)
+		}
+	}()
+
+	if err := fn(t); err != nil {
+		if t.active() {
+			return errors.Join(err, t.rollback(ctx))
+		}
+		return err
 	}
-	return nil
+	// fn may commit by itself
+	if t.state == TRANSACTION_COMMITTED {
+		return nil
+	}
+	return t.Commit()
 }
 
 // ScannerFindOne is a scanner helper function. Returns true if at least one row is returned. Doesn't throw ErrNotFound or ErrFound. Instead returns false.
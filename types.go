@@ -24,6 +24,10 @@ type Enum struct {
 	kind           reflect.Kind
 	registerErrors error
 	options        []any
+	// Values of the database renamed to registered values
+	renames map[string]string
+	// Registered values that replace the removed values of the database
+	replacements map[string]string
 }
 
 const (
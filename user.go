@@ -14,6 +14,8 @@ type User struct {
 	password string
 	UserMethods
 
+	registerErrors error
+
 	*Role
 }
 
@@ -56,9 +58,20 @@ func (r *User) WithPassword(password string) *User {
 }
 func RegisterUser(name, password string) *User {
 	user := newUser(name, password)
+	user.registerErrors = validateIdentifier("user", string(user.Name))
 	(*roles)[user.Name] = user
 	return user
 }
+
+// LookupUser returns the registered user called name. Unregistered users are returned without a password and without being registered.
+func LookupUser(name string) *User {
+	user := newUser(name, "")
+	if registered, ok := (*roles)[user.Name].(*User); ok {
+		return registered
+	}
+	user.registerErrors = validateIdentifier("user", string(user.Name))
+	return user
+}
 func newUser(name, password string) *User {
 	roleName := RoleName(strings.ReplaceAll(strings.ToLower(name), " ", "_"))
 	return &User{Role: &Role{Name: roleName, Type: USER}, password: password}